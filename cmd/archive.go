@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 并发收集/压缩时使用的默认 worker 数量
+var saveWorkers = runtime.NumCPU()
+
+// zipEntry 描述一个待写入 orbit 包的条目
+type zipEntry struct {
+	Name    string // zip 内的路径，使用 / 分隔
+	Path    string // 源文件路径，目录条目和内存条目为空
+	Data    []byte // 内存中的内容，非 nil 时优先于 Path
+	IsDir   bool
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+// normalizeEntries 按包内路径排序，并把时间和权限统一，供可重现模式使用
+func normalizeEntries(entries []zipEntry, modTime time.Time) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	for i := range entries {
+		entries[i].ModTime = modTime
+		if entries[i].IsDir {
+			entries[i].Mode = fs.ModeDir | 0755
+		} else {
+			entries[i].Mode = 0644
+		}
+	}
+}
+
+// compressedEntry 是 worker 压缩好的条目，等待按顺序写入
+type compressedEntry struct {
+	header *zip.FileHeader
+	data   []byte
+	err    error
+}
+
+// normalizeWorkers 保证 worker 数量至少为 1
+func normalizeWorkers(workers int) int {
+	if workers < 1 {
+		return 1
+	}
+	return workers
+}
+
+var flateWriterPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	},
+}
+
+// compressEntry 读取并压缩单个条目，返回可直接写入 zip 的原始数据
+func compressEntry(entry zipEntry) compressedEntry {
+	header := &zip.FileHeader{
+		Name:     entry.Name,
+		Method:   zip.Store,
+		Modified: entry.ModTime,
+	}
+	header.SetMode(entry.Mode)
+
+	if entry.IsDir {
+		return compressedEntry{header: header}
+	}
+
+	content := entry.Data
+	if content == nil {
+		var err error
+		content, err = os.ReadFile(entry.Path)
+		if err != nil {
+			return compressedEntry{err: err}
+		}
+	}
+
+	var buf bytes.Buffer
+	fw := flateWriterPool.Get().(*flate.Writer)
+	fw.Reset(&buf)
+	_, err := fw.Write(content)
+	if err == nil {
+		err = fw.Close()
+	}
+	flateWriterPool.Put(fw)
+	if err != nil {
+		return compressedEntry{err: err}
+	}
+
+	header.Method = zip.Deflate
+	header.CRC32 = crc32.ChecksumIEEE(content)
+	header.UncompressedSize64 = uint64(len(content))
+	header.CompressedSize64 = uint64(buf.Len())
+
+	return compressedEntry{header: header, data: buf.Bytes()}
+}
+
+// writeZipEntries 并发读取并压缩条目，再严格按 entries 的顺序写入 zipWriter。
+// 同时在途的条目数量受限，避免大目录把压缩结果全部堆在内存里。
+func writeZipEntries(ctx context.Context, zipWriter *zip.Writer, entries []zipEntry, workers int) error {
+	workers = normalizeWorkers(workers)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan compressedEntry, len(entries))
+	for i := range results {
+		results[i] = make(chan compressedEntry, 1)
+	}
+
+	jobCh := make(chan int)
+	window := make(chan struct{}, workers*4)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobCh {
+				if err := ctx.Err(); err != nil {
+					results[idx] <- compressedEntry{err: err}
+					continue
+				}
+				results[idx] <- compressEntry(entries[idx])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobCh)
+		for idx := range entries {
+			select {
+			case <-ctx.Done():
+				return
+			case window <- struct{}{}:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobCh <- idx:
+			}
+		}
+	}()
+
+	var writeErr error
+	for idx := range entries {
+		var result compressedEntry
+		select {
+		case <-ctx.Done():
+			writeErr = ctx.Err()
+		case result = <-results[idx]:
+			writeErr = result.err
+		}
+		if writeErr != nil {
+			break
+		}
+
+		w, err := zipWriter.CreateRaw(result.header)
+		if err == nil && len(result.data) > 0 {
+			_, err = w.Write(result.data)
+		}
+		<-window
+		if err != nil {
+			writeErr = err
+			break
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	return writeErr
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// makeSyntheticTree 创建 n 个小文件组成的目录树，每个目录 100 个文件
+func makeSyntheticTree(tb testing.TB, root string, n int) {
+	tb.Helper()
+	for i := 0; i < n; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%04d", i/100))
+		if i%100 == 0 {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				tb.Fatal(err)
+			}
+		}
+		content := bytes.Repeat([]byte(fmt.Sprintf("file %d\n", i)), 64)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%05d.json", i)), content, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// treeEntries 把目录树映射为包根目录下的条目
+func treeEntries(tb testing.TB, root string) []zipEntry {
+	tb.Helper()
+	set := newEntrySet()
+	if err := set.AddTree(context.Background(), "", root); err != nil {
+		tb.Fatal(err)
+	}
+	return set.entries
+}
+
+func zipToBytes(tb testing.TB, ctx context.Context, root string, workers int) ([]byte, error) {
+	tb.Helper()
+	entries := treeEntries(tb, root)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeZipEntries(ctx, zw, entries, workers); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func TestWriteZipEntriesKeepsOrderAndContent(t *testing.T) {
+	root := t.TempDir()
+	makeSyntheticTree(t, root, 500)
+
+	data, err := zipToBytes(t, context.Background(), root, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := treeEntries(t, root)
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(r.File), len(entries))
+	}
+
+	for i, f := range r.File {
+		if f.Name != entries[i].Name {
+			t.Fatalf("entry %d: got %s, want %s", i, f.Name, entries[i].Name)
+		}
+		if entries[i].IsDir {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		want, _ := os.ReadFile(entries[i].Path)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: content mismatch", f.Name)
+		}
+	}
+
+	sequential, err := zipToBytes(t, context.Background(), root, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, sequential) {
+		t.Fatal("parallel output differs from sequential output")
+	}
+}
+
+func TestWriteZipEntriesCancel(t *testing.T) {
+	root := t.TempDir()
+	makeSyntheticTree(t, root, 200)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := zipToBytes(t, ctx, root, 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestEntrySetAddTree(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "snippets"), 0755)
+	os.WriteFile(filepath.Join(root, "settings.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(root, "snippets", "go.json"), []byte("{}"), 0644)
+
+	set := newEntrySet()
+	if err := set.AddTree(context.Background(), "configs/vscode_config_dir/APPDATA/Code/User", root); err != nil {
+		t.Fatal(err)
+	}
+	set.AddData("manifest.json", []byte("{}"), time.Now())
+
+	var names []string
+	for _, e := range set.entries {
+		names = append(names, e.Name)
+	}
+	want := []string{
+		"configs/",
+		"configs/vscode_config_dir/",
+		"configs/vscode_config_dir/APPDATA/",
+		"configs/vscode_config_dir/APPDATA/Code/",
+		"configs/vscode_config_dir/APPDATA/Code/User/",
+		"configs/vscode_config_dir/APPDATA/Code/User/settings.json",
+		"configs/vscode_config_dir/APPDATA/Code/User/snippets/",
+		"configs/vscode_config_dir/APPDATA/Code/User/snippets/go.json",
+		"manifest.json",
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got entries:\n%s\nwant:\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+
+	// 源文件直接引用原路径，不经过暂存目录
+	if set.entries[5].Path != filepath.Join(root, "settings.json") {
+		t.Fatalf("got source path %s", set.entries[5].Path)
+	}
+}
+
+var (
+	benchTreeOnce sync.Once
+	benchTreeRoot string
+)
+
+// benchTree 只生成一次 50k 文件的目录树，供所有 benchmark 共用
+func benchTree(b *testing.B) string {
+	benchTreeOnce.Do(func() {
+		benchTreeRoot, _ = filepath.Abs("bench-tree")
+		makeSyntheticTree(b, benchTreeRoot, 50000)
+	})
+	return benchTreeRoot
+}
+
+// benchWorkerCases 对比单 worker 与并发 worker 的耗时
+func benchWorkerCases() []struct {
+	name    string
+	workers int
+} {
+	return []struct {
+		name    string
+		workers int
+	}{
+		{"sequential", 1},
+		{fmt.Sprintf("parallel-%d", max(runtime.NumCPU(), 4)), max(runtime.NumCPU(), 4)},
+	}
+}
+
+func BenchmarkCreateOrbitZip(b *testing.B) {
+	root := benchTree(b)
+
+	for _, bc := range benchWorkerCases() {
+		workers := bc.workers
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := zipToBytes(b, context.Background(), root, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReproducibleArchive(t *testing.T) {
+	home := t.TempDir()
+	oldConfigDir, oldUserDir := CodeConfigDir, CodeUserDir
+	CodeConfigDir = filepath.Join(home, "AppData", "Code")
+	CodeUserDir = filepath.Join(home, ".vscode")
+	defer func() { CodeConfigDir, CodeUserDir = oldConfigDir, oldUserDir }()
+
+	makeSyntheticTree(t, filepath.Join(CodeConfigDir, "User"), 300)
+	makeSyntheticTree(t, filepath.Join(CodeUserDir, "extensions"), 50)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	oldReproducible := reproducible
+	reproducible = true
+	defer func() { reproducible = oldReproducible }()
+
+	opts, err := newBackupOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opts.SourceDate.Unix(); got != 1700000000 {
+		t.Fatalf("got source date %d", got)
+	}
+
+	// 配置包中不应包含本机的修改时间和备份计数
+	oldConfigManager := globalConfigManager
+	globalConfigManager = newTestConfigManager(t)
+	defer func() { globalConfigManager = oldConfigManager }()
+
+	build := func() [32]byte {
+		providers := []backupProvider{vscodeProvider{}, configBundleProvider{opts}, manifestProvider{opts}}
+		data, err := buildOrbitArchive(context.Background(), opts, providers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha256.Sum256(data)
+	}
+
+	first := build()
+
+	// 修改时间变化不应影响输出
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(CodeConfigDir, "User", "dir0000", "f00000.json"), later, later)
+	if err := globalConfigManager.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount++ }); err != nil {
+		t.Fatal(err)
+	}
+	globalConfigManager.userConfig.LastUpdate = "2000-01-01 00:00:00"
+
+	if second := build(); first != second {
+		t.Fatalf("archives differ: %x != %x", first, second)
+	}
+}
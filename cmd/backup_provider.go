@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// backupProvider 为 orbit 包提供条目。
+// 普通文件直接以源路径 + 包内路径的形式交给 archive writer 流式写入，
+// 只有需要生成或转换内容的 provider 才使用 backupStaging 暂存。
+type backupProvider interface {
+	Name() string
+	Collect(ctx context.Context, stage *backupStaging, set *entrySet) error
+}
+
+// backupStaging 是按需创建的暂存目录，没有 provider 使用时不会落盘
+type backupStaging struct {
+	dir string
+}
+
+// Dir 返回暂存目录，第一次调用时才创建
+func (s *backupStaging) Dir() (string, error) {
+	if s.dir != "" {
+		return s.dir, nil
+	}
+
+	dir, err := os.MkdirTemp("", "orbit-staging")
+	if err != nil {
+		return "", err
+	}
+	logger.Infof("成功创建暂存目录：%v", dir)
+	s.dir = dir
+	return dir, nil
+}
+
+// Cleanup 删除暂存目录
+func (s *backupStaging) Cleanup() {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+}
+
+// entrySet 收集 provider 产出的条目，自动补齐并去重父目录条目
+type entrySet struct {
+	entries []zipEntry
+	dirs    map[string]bool
+}
+
+func newEntrySet() *entrySet {
+	return &entrySet{dirs: make(map[string]bool)}
+}
+
+// AddDir 添加目录条目（包括所有尚未添加的父目录）
+func (s *entrySet) AddDir(name string, modTime time.Time) {
+	name = path.Clean(name)
+	if name == "." || name == "/" || s.dirs[name] {
+		return
+	}
+	s.AddDir(path.Dir(name), modTime)
+
+	s.dirs[name] = true
+	s.entries = append(s.entries, zipEntry{
+		Name:    name + "/",
+		IsDir:   true,
+		Mode:    fs.ModeDir | 0755,
+		ModTime: modTime,
+	})
+}
+
+// AddFile 添加一个从源文件流式读取的条目
+func (s *entrySet) AddFile(name, srcPath string, info fs.FileInfo) {
+	s.AddDir(path.Dir(name), info.ModTime())
+	s.entries = append(s.entries, zipEntry{
+		Name:    name,
+		Path:    srcPath,
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	})
+}
+
+// AddData 添加一个内容已在内存中的条目
+func (s *entrySet) AddData(name string, data []byte, modTime time.Time) {
+	s.AddDir(path.Dir(name), modTime)
+	s.entries = append(s.entries, zipEntry{
+		Name:    name,
+		Data:    data,
+		Mode:    0644,
+		ModTime: modTime,
+	})
+}
+
+// AddTree 把 srcDir 下的内容映射到包内 archiveDir 下
+func (s *entrySet) AddTree(ctx context.Context, archiveDir, srcDir string) error {
+	s.AddDir(archiveDir, time.Now())
+
+	return filepath.Walk(srcDir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+
+		name := path.Join(archiveDir, filepath.ToSlash(relPath))
+		if info.IsDir() {
+			s.AddDir(name, info.ModTime())
+		} else {
+			s.AddFile(name, p, info)
+		}
+		return nil
+	})
+}
+
+// collectFromProviders 依次运行 provider，返回全部条目
+func collectFromProviders(ctx context.Context, stage *backupStaging, providers []backupProvider) ([]zipEntry, error) {
+	set := newEntrySet()
+	for _, provider := range providers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		logger.Infof("正在收集 %s ...", provider.Name())
+		if err := provider.Collect(ctx, stage, set); err != nil {
+			return nil, err
+		}
+	}
+	return set.entries, nil
+}
@@ -0,0 +1,594 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 软件目录相关的文件名
+const (
+	SoftwareCatalogFileName  = "software-catalog.json" // 当前目录中的本地目录，也是目录来源中的文件名
+	CatalogSignatureSuffix   = ".sig"                  // 签名文件的后缀，内容为 base64 编码的 RSA PKCS#1 v1.5 SHA-256 签名
+	CatalogCacheDirName      = "catalog-cache"         // 远程目录的缓存目录，与 info.json 位于同一目录
+	DefaultCatalogTTL        = 24 * time.Hour          // 服务器没有指定缓存时间时的有效期
+	catalogDownloadTimeout   = 30 * time.Second
+	catalogMaxDownloadLength = 16 * 1024 * 1024
+)
+
+// 目录条目的来源名称
+const (
+	catalogOriginLocal   = "local"
+	catalogOriginBuiltin = "built-in"
+)
+
+// catalogEntry 是合并后目录中的一个软件及其来源
+type catalogEntry struct {
+	DesiredSoftware
+	Origin string `json:"origin"` // 目录来源，local、built-in 或 software.catalog_sources 中的一项
+}
+
+// catalogSourceStatus 是一个目录来源的加载结果
+type catalogSourceStatus struct {
+	Source  string
+	Entries int
+	Cached  bool // 使用了本地缓存（未过期或服务器返回 304）
+	Err     error
+}
+
+// catalogCacheMeta 记录缓存的远程目录的 ETag 和有效期
+type catalogCacheMeta struct {
+	URL       string    `json:"url"`
+	ETag      string    `json:"etag,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Expires   time.Time `json:"expires"`
+}
+
+// catalogLoader 按优先级加载并合并软件目录：当前目录的 software-catalog.json，然后是
+// software.catalog_sources 中的各个来源，两者都没有配置时使用内置的目录
+type catalogLoader struct {
+	Sources   []string
+	PublicKey *rsa.PublicKey // 验证来源签名的公钥，为 nil 时拒绝所有来源
+	CacheDir  string         // 为空时不缓存远程目录
+	LocalPath string         // 当前目录的本地目录，不需要签名
+	Client    *http.Client
+	Now       func() time.Time
+}
+
+// newCatalogLoader 根据配置创建目录加载器。公钥无法加载时所有来源都会被拒绝。
+func newCatalogLoader(config SoftwareConfig) *catalogLoader {
+	loader := &catalogLoader{
+		Sources:   config.CatalogSources,
+		LocalPath: SoftwareCatalogFileName,
+		Client:    &http.Client{Timeout: catalogDownloadTimeout},
+		Now:       time.Now,
+	}
+	if configManager := GetConfigManager(); configManager != nil && configManager.IsConfigLoaded() {
+		loader.CacheDir = filepath.Join(filepath.Dir(configManager.configPath), CatalogCacheDirName)
+	}
+	if len(config.CatalogSources) > 0 && config.CatalogPublicKey != "" {
+		publicKey, err := LoadPublicKey(config.CatalogPublicKey)
+		if err != nil {
+			logger.Warnf("加载目录公钥失败: %v", err)
+		} else {
+			loader.PublicKey = publicKey
+		}
+	}
+	return loader
+}
+
+// Load 加载所有来源并合并，同名（按 normalizeSoftwareName 比较）的软件以优先级高的来源为准。
+// refresh 为 true 时忽略缓存的有效期，向服务器重新验证。加载失败的来源记录在状态中并跳过；
+// 本地目录无法解析，或者配置了来源但全部加载失败时，同时返回错误和已加载的条目。
+func (l *catalogLoader) Load(ctx context.Context, refresh bool) ([]catalogEntry, []catalogSourceStatus, error) {
+	var entries []catalogEntry
+	seen := make(map[string]bool)
+	add := func(software []DesiredSoftware, origin string) {
+		for _, item := range software {
+			name := normalizeSoftwareName(item.Name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			entries = append(entries, catalogEntry{DesiredSoftware: item, Origin: origin})
+		}
+	}
+
+	var statuses []catalogSourceStatus
+	var loadErr error
+	hasLocal := false
+	if l.LocalPath != "" {
+		if data, err := os.ReadFile(l.LocalPath); err == nil {
+			hasLocal = true
+			status := catalogSourceStatus{Source: l.LocalPath}
+			catalog, err := parseCatalog(data)
+			if err != nil {
+				status.Err = err
+				loadErr = fmt.Errorf("解析 %s 失败: %w", l.LocalPath, err)
+			} else {
+				add(catalog.Software, catalogOriginLocal)
+				status.Entries = len(catalog.Software)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	failed := 0
+	var lastErr error
+	for _, source := range l.Sources {
+		catalog, cached, err := l.loadSource(ctx, source, refresh)
+		status := catalogSourceStatus{Source: source, Cached: cached, Err: err}
+		if err == nil {
+			add(catalog.Software, source)
+			status.Entries = len(catalog.Software)
+		} else {
+			failed++
+			lastErr = err
+		}
+		statuses = append(statuses, status)
+	}
+	if loadErr == nil && len(l.Sources) > 0 && failed == len(l.Sources) {
+		loadErr = fmt.Errorf("所有软件目录来源都加载失败: %w", lastErr)
+	}
+
+	if !hasLocal && len(l.Sources) == 0 {
+		add(createDefaultCatalog().Software, catalogOriginBuiltin)
+	}
+	return entries, statuses, loadErr
+}
+
+// parseCatalog 解析目录文件
+func parseCatalog(data []byte) (*SoftwareCatalog, error) {
+	var catalog SoftwareCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("解析软件目录失败: %v", err)
+	}
+	return &catalog, nil
+}
+
+// verifyCatalog 用公钥验证目录内容和签名
+func (l *catalogLoader) verifyCatalog(data, signature []byte) error {
+	if l.PublicKey == nil {
+		return fmt.Errorf("没有配置 software.catalog_public_key，无法验证目录签名")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("目录签名格式无效: %v", err)
+	}
+	digest := sha256.Sum256(data)
+	if err := rsa.VerifyPKCS1v15(l.PublicKey, crypto.SHA256, digest[:], decoded); err != nil {
+		return fmt.Errorf("目录签名验证失败，目录可能被篡改")
+	}
+	return nil
+}
+
+// signCatalog 用私钥为目录内容生成签名文件的内容
+func signCatalog(data []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n"), nil
+}
+
+// isCatalogURL 判断来源是否为 http(s) URL
+func isCatalogURL(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// loadSource 加载一个来源并验证签名，返回是否使用了缓存
+func (l *catalogLoader) loadSource(ctx context.Context, source string, refresh bool) (*SoftwareCatalog, bool, error) {
+	if isCatalogURL(source) {
+		return l.loadURL(ctx, source, refresh)
+	}
+
+	// 目录来源读取其中的 software-catalog.json，也可以直接指定文件
+	path := source
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, SoftwareCatalogFileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	signature, err := os.ReadFile(path + CatalogSignatureSuffix)
+	if err != nil {
+		return nil, false, fmt.Errorf("读取目录签名失败: %v", err)
+	}
+	if err := l.verifyCatalog(data, signature); err != nil {
+		return nil, false, err
+	}
+	catalog, err := parseCatalog(data)
+	return catalog, false, err
+}
+
+// cachePaths 返回 URL 对应的缓存文件：目录、签名和元数据
+func (l *catalogLoader) cachePaths(url string) (string, string, string) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(l.CacheDir, hex.EncodeToString(sum[:8]))
+	return base + ".json", base + ".json" + CatalogSignatureSuffix, base + ".meta.json"
+}
+
+// readCache 读取并验证缓存的目录，没有缓存时 meta 为 nil
+func (l *catalogLoader) readCache(url string) ([]byte, *catalogCacheMeta, error) {
+	if l.CacheDir == "" {
+		return nil, nil, nil
+	}
+	dataPath, sigPath, metaPath := l.cachePaths(url)
+
+	metaData, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var meta catalogCacheMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		return nil, nil, fmt.Errorf("解析目录缓存失败: %v", err)
+	}
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 缓存同样可能被修改，每次使用前都重新验证
+	if err := l.verifyCatalog(data, signature); err != nil {
+		return nil, nil, fmt.Errorf("目录缓存: %w", err)
+	}
+	return data, &meta, nil
+}
+
+// writeCache 保存下载并验证过的目录
+func (l *catalogLoader) writeCache(url string, data, signature []byte, meta catalogCacheMeta) error {
+	if l.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(l.CacheDir, 0755); err != nil {
+		return err
+	}
+	dataPath, sigPath, metaPath := l.cachePaths(url)
+	if err := writeFileAtomic(dataPath, data, 0644); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(sigPath, signature, 0644); err != nil {
+		return err
+	}
+	return l.writeCacheMeta(metaPath, meta)
+}
+
+func (l *catalogLoader) writeCacheMeta(metaPath string, meta catalogCacheMeta) error {
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, metaData, 0644)
+}
+
+// loadURL 加载远程目录：缓存未过期时直接使用，否则带 If-None-Match 重新请求。
+// 下载失败或新目录验证失败时退回到仍然有效签名的旧缓存。
+func (l *catalogLoader) loadURL(ctx context.Context, url string, refresh bool) (*SoftwareCatalog, bool, error) {
+	cached, meta, err := l.readCache(url)
+	if err != nil {
+		logger.Warnf("忽略 %s 的缓存: %v", url, err)
+		cached, meta = nil, nil
+	}
+	now := l.Now()
+	if meta != nil && !refresh && now.Before(meta.Expires) {
+		catalog, err := parseCatalog(cached)
+		return catalog, true, err
+	}
+
+	etag := ""
+	if meta != nil {
+		etag = meta.ETag
+	}
+	data, signature, header, notModified, err := l.download(ctx, url, etag)
+	if err == nil && notModified {
+		meta.Expires = catalogExpiry(header, now)
+		_, _, metaPath := l.cachePaths(url)
+		if err := l.writeCacheMeta(metaPath, *meta); err != nil {
+			logger.Warnf("更新目录缓存失败: %v", err)
+		}
+		catalog, err := parseCatalog(cached)
+		return catalog, true, err
+	}
+	if err == nil {
+		err = l.verifyCatalog(data, signature)
+	}
+	if err != nil {
+		if meta == nil {
+			return nil, false, err
+		}
+		logger.Warnf("更新 %s 失败，使用 %s 缓存的目录: %v", url, meta.FetchedAt.Local().Format("2006-01-02 15:04"), err)
+		catalog, parseErr := parseCatalog(cached)
+		return catalog, true, parseErr
+	}
+
+	catalog, err := parseCatalog(data)
+	if err != nil {
+		return nil, false, err
+	}
+	newMeta := catalogCacheMeta{URL: url, ETag: header.Get("ETag"), FetchedAt: now, Expires: catalogExpiry(header, now)}
+	if err := l.writeCache(url, data, signature, newMeta); err != nil {
+		logger.Warnf("保存目录缓存失败: %v", err)
+	}
+	return catalog, false, nil
+}
+
+// download 下载目录和签名，etag 不为空时服务器可以返回 304
+func (l *catalogLoader) download(ctx context.Context, url, etag string) ([]byte, []byte, http.Header, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, nil, resp.Header, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, false, fmt.Errorf("下载目录失败: %s", resp.Status)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	sigReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url+CatalogSignatureSuffix, nil)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	sigResp, err := l.Client.Do(sigReq)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	defer sigResp.Body.Close()
+	if sigResp.StatusCode != http.StatusOK {
+		return nil, nil, nil, false, fmt.Errorf("下载目录签名失败: %s", sigResp.Status)
+	}
+	signature, err := readLimited(sigResp.Body)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	return data, signature, resp.Header, false, nil
+}
+
+// readLimited 读取响应内容，超过 catalogMaxDownloadLength 时返回错误
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, catalogMaxDownloadLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > catalogMaxDownloadLength {
+		return nil, fmt.Errorf("目录超过 %d MB", catalogMaxDownloadLength/1024/1024)
+	}
+	return data, nil
+}
+
+// catalogExpiry 根据 Cache-Control 的 max-age 或 Expires 计算缓存的过期时间
+func catalogExpiry(header http.Header, now time.Time) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return now
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return now.Add(DefaultCatalogTTL)
+}
+
+// loadCatalogEntries 按配置加载合并后的目录，加载失败的来源记录为警告
+func loadCatalogEntries(ctx context.Context, refresh bool) ([]catalogEntry, []catalogSourceStatus, error) {
+	entries, statuses, err := newCatalogLoader(currentSoftwareConfig()).Load(ctx, refresh)
+	for _, status := range statuses {
+		if status.Err != nil {
+			logger.Warnf("跳过软件目录 %s: %v", status.Source, status.Err)
+		}
+	}
+	return entries, statuses, err
+}
+
+// writeCatalogEntries 以 table 或 json 格式输出目录
+func writeCatalogEntries(w io.Writer, entries []catalogEntry, format string) error {
+	switch format {
+	case "json":
+		if entries == nil {
+			entries = []catalogEntry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tVERSION\tCATEGORY\tSOURCE")
+		for _, entry := range entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Name, entry.Version, entry.Category, entry.Origin)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("不支持的输出格式: %s (可用 table、json)", format)
+}
+
+// catalog 命令的参数
+var (
+	catalogListFormat string
+	catalogSignKey    string
+)
+
+// catalogCmd 是软件目录相关命令的父命令
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage the software catalog used by install and plan",
+	Long: `The software catalog tells 'orbit install' and 'orbit plan' where to download
+each app. It is merged from, in priority order:
+  1. software-catalog.json in the current directory
+  2. the sources in software.catalog_sources, in the order listed
+  3. a small built-in catalog, only when neither of the above is configured
+
+A source is an http(s) URL of a catalog file, or a directory containing
+software-catalog.json (a path to the file also works). Every source must be
+signed: the signature is read from the same location with ".sig" appended and
+verified with the public key in software.catalog_public_key. Catalogs with a
+missing or invalid signature are rejected. Remote catalogs are cached next to
+info.json and revalidated with ETag once they expire (Cache-Control max-age or
+Expires, otherwise 24 hours).
+
+Examples:
+  orbit config add software.catalog_sources https://example.com/software-catalog.json
+  orbit config set software.catalog_public_key ./keys/catalog_public.pem
+  orbit catalog sign software-catalog.json --private-key ./keys/catalog_private.pem`,
+}
+
+// catalogUpdateCmd 重新下载远程目录
+var catalogUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Refresh the cached catalogs from their sources",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(currentSoftwareConfig().CatalogSources) == 0 {
+			logger.Info("没有配置软件目录来源 (software.catalog_sources)")
+		}
+		entries, statuses, _ := loadCatalogEntries(cmd.Context(), true)
+
+		failed := 0
+		for _, status := range statuses {
+			switch {
+			case status.Err != nil:
+				failed++
+			case status.Cached:
+				logger.Infof("%s: %d 个软件 (未变化)", status.Source, status.Entries)
+			default:
+				logger.Infof("%s: %d 个软件", status.Source, status.Entries)
+			}
+		}
+		logger.Infof("合并后的目录共有 %d 个软件", len(entries))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// catalogListCmd 列出合并后的目录
+var catalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the software in the merged catalog",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, _, err := loadCatalogEntries(cmd.Context(), false)
+		if err != nil {
+			logger.Errorf("加载软件目录失败: %v", err)
+			os.Exit(1)
+		}
+		if err := writeCatalogEntries(os.Stdout, entries, catalogListFormat); err != nil {
+			logger.Errorf("输出软件目录失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// catalogShowCmd 显示一个目录条目
+var catalogShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a catalog entry and the source it comes from",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, _, err := loadCatalogEntries(cmd.Context(), false)
+		if err != nil {
+			logger.Errorf("加载软件目录失败: %v", err)
+			os.Exit(1)
+		}
+		for _, entry := range entries {
+			if catalogEntryNamed(entry.DesiredSoftware, args[0]) {
+				data, _ := json.MarshalIndent(entry, "", "  ")
+				fmt.Println(string(data))
+				return
+			}
+		}
+		logger.Errorf("软件目录中没有 %s", args[0])
+		os.Exit(1)
+	},
+}
+
+// catalogSignCmd 为目录文件生成签名
+var catalogSignCmd = &cobra.Command{
+	Use:   "sign <catalog.json>",
+	Short: "Sign a catalog file for publishing",
+	Long: `Sign a catalog file with an RSA private key and write the signature to
+<catalog.json>.sig. Publish both files together; machines verify them with the
+matching public key in software.catalog_public_key.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			logger.Errorf("读取目录失败: %v", err)
+			os.Exit(1)
+		}
+		if _, err := parseCatalog(data); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		privateKey, err := LoadPrivateKey(catalogSignKey)
+		if err != nil {
+			logger.Errorf("加载私钥失败: %v", err)
+			os.Exit(1)
+		}
+		signature, err := signCatalog(data, privateKey)
+		if err != nil {
+			logger.Errorf("签名失败: %v", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(args[0]+CatalogSignatureSuffix, signature, 0644); err != nil {
+			logger.Errorf("写入签名失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("签名已写入 %s", args[0]+CatalogSignatureSuffix)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+	catalogCmd.AddCommand(catalogUpdateCmd)
+	catalogCmd.AddCommand(catalogListCmd)
+	catalogCmd.AddCommand(catalogShowCmd)
+	catalogCmd.AddCommand(catalogSignCmd)
+
+	catalogListCmd.Flags().StringVar(&catalogListFormat, "format", "table", "Output format: table or json")
+	catalogSignCmd.Flags().StringVar(&catalogSignKey, "private-key", "", "RSA private key (PEM) used to sign the catalog")
+	catalogSignCmd.MarkFlagRequired("private-key")
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeSignedCatalog 写入目录文件和签名
+func writeSignedCatalog(t *testing.T, path string, catalog SoftwareCatalog, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signCatalog(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+CatalogSignatureSuffix, signature, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCatalogLoader(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 远程目录：catalog.json 有效，tampered.json 用另一个密钥签名
+	served := t.TempDir()
+	remote := writeSignedCatalog(t, filepath.Join(served, "catalog.json"), SoftwareCatalog{Software: []DesiredSoftware{
+		{Name: "Mozilla Firefox", DownloadURL: "https://example.com/firefox.exe"},
+		{Name: "Git", DownloadURL: "https://example.com/remote-git.exe"},
+	}}, key)
+	writeSignedCatalog(t, filepath.Join(served, "tampered.json"), SoftwareCatalog{Software: []DesiredSoftware{
+		{Name: "Evil", DownloadURL: "https://example.com/evil.exe"},
+	}}, otherKey)
+
+	var downloads, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/catalog.json" {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "max-age=3600")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt32(&downloads, 1)
+		}
+		http.ServeFile(w, r, filepath.Join(served, filepath.Base(r.URL.Path)))
+	}))
+	defer server.Close()
+
+	// 目录来源
+	dirSource := t.TempDir()
+	writeSignedCatalog(t, filepath.Join(dirSource, SoftwareCatalogFileName), SoftwareCatalog{Software: []DesiredSoftware{
+		{Name: "Mozilla Firefox (x64 en-US)", DownloadURL: "https://example.com/dir-firefox.exe"},
+		{Name: "7-Zip", DownloadURL: "https://example.com/7z.exe"},
+	}}, key)
+
+	// 当前目录的本地目录不需要签名，优先级最高
+	localPath := filepath.Join(t.TempDir(), SoftwareCatalogFileName)
+	if err := os.WriteFile(localPath, []byte(`{"software": [{"name": "Git", "downloadURL": "https://example.com/local-git.exe"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	loader := &catalogLoader{
+		Sources:   []string{server.URL + "/catalog.json", dirSource, server.URL + "/tampered.json"},
+		PublicKey: &key.PublicKey,
+		CacheDir:  t.TempDir(),
+		LocalPath: localPath,
+		Client:    server.Client(),
+		Now:       func() time.Time { return now },
+	}
+
+	entries, statuses, err := loader.Load(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, entry := range entries {
+		got[entry.Name] = entry.DownloadURL
+	}
+	want := map[string]string{
+		"Git":             "https://example.com/local-git.exe",
+		"Mozilla Firefox": "https://example.com/firefox.exe",
+		"7-Zip":           "https://example.com/7z.exe",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("merged catalog: %+v", entries)
+	}
+	for name, url := range want {
+		if got[name] != url {
+			t.Fatalf("%s: got %q, want %q", name, got[name], url)
+		}
+	}
+	if len(statuses) != 4 || statuses[3].Err == nil || statuses[1].Cached {
+		t.Fatalf("unexpected statuses: %+v", statuses)
+	}
+
+	// 缓存未过期时不发请求
+	_, statuses, _ = loader.Load(context.Background(), false)
+	if downloads != 1 || notModified != 0 || !statuses[1].Cached {
+		t.Fatalf("expected cached catalog: downloads=%d notModified=%d %+v", downloads, notModified, statuses[1])
+	}
+
+	// 过期后带 ETag 重新验证，服务器返回 304
+	now = now.Add(2 * time.Hour)
+	_, statuses, _ = loader.Load(context.Background(), false)
+	if downloads != 1 || notModified != 1 || !statuses[1].Cached || statuses[1].Err != nil {
+		t.Fatalf("expected revalidation: downloads=%d notModified=%d %+v", downloads, notModified, statuses[1])
+	}
+
+	// 被修改的缓存不会被使用，重新下载
+	dataPath, _, _ := loader.cachePaths(server.URL + "/catalog.json")
+	if err := os.WriteFile(dataPath, []byte(`{"software": [{"name": "Evil"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	catalog, _, err := loader.loadSource(context.Background(), server.URL+"/catalog.json", false)
+	if err != nil || len(catalog.Software) != 2 || downloads != 2 {
+		t.Fatalf("expected fresh download after tampered cache: %+v, %v, downloads=%d", catalog, err, downloads)
+	}
+	if cached, _ := os.ReadFile(dataPath); string(cached) != string(remote) {
+		t.Fatal("cache should hold the verified catalog")
+	}
+
+	// 服务器不可用时使用缓存
+	server.Close()
+	catalog, cached, err := loader.loadSource(context.Background(), server.URL+"/catalog.json", true)
+	if err != nil || !cached || len(catalog.Software) != 2 {
+		t.Fatalf("expected stale cache: %+v, %v, %v", catalog, cached, err)
+	}
+
+	// 没有公钥时拒绝所有来源，只剩本地目录，并且返回错误
+	loader.PublicKey = nil
+	entries, _, err = loader.Load(context.Background(), false)
+	if err == nil || len(entries) != 1 || entries[0].Origin != catalogOriginLocal {
+		t.Fatalf("unsigned sources should be rejected: %+v, %v", entries, err)
+	}
+
+	// 来源全部失败时不使用内置目录
+	loader.LocalPath = ""
+	entries, _, err = loader.Load(context.Background(), false)
+	if err == nil || len(entries) != 0 {
+		t.Fatalf("expected an error without built-in fallback: %+v, %v", entries, err)
+	}
+
+	// 本地目录无法解析时返回错误
+	if err := os.WriteFile(localPath, []byte(`{"software": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loader.LocalPath = localPath
+	loader.Sources = nil
+	if entries, _, err = loader.Load(context.Background(), false); err == nil || len(entries) != 0 {
+		t.Fatalf("broken local catalog should fail: %+v, %v", entries, err)
+	}
+
+	// 什么都没有配置时使用内置目录
+	loader.LocalPath = ""
+	entries, _, err = loader.Load(context.Background(), false)
+	if err != nil || len(entries) == 0 || entries[0].Origin != catalogOriginBuiltin {
+		t.Fatalf("expected built-in catalog: %+v, %v", entries, err)
+	}
+
+	expires := catalogExpiry(http.Header{"Cache-Control": {"public, max-age=60"}}, now)
+	if !expires.Equal(now.Add(time.Minute)) || !catalogExpiry(http.Header{}, now).Equal(now.Add(DefaultCatalogTTL)) {
+		t.Fatalf("unexpected expiry %v", expires)
+	}
+}
+
+func TestInstallerFileName(t *testing.T) {
+	tests := []struct {
+		software DesiredSoftware
+		url      string
+		want     string
+	}{
+		{DesiredSoftware{Name: "7-Zip", Installer: "exe"}, "https://www.7-zip.org/a/7z2409-x64.exe", "7z2409-x64.exe"},
+		{DesiredSoftware{Name: "Mozilla Firefox", Installer: "exe"}, "https://download.mozilla.org/?product=firefox-latest&os=win64&lang=en-US", "Mozilla_Firefox.exe"},
+		{DesiredSoftware{Name: "Tool"}, "https://example.com/download?id=1", "Tool"},
+	}
+	for _, tt := range tests {
+		if got := installerFileName(tt.software, tt.url); got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	// 内置目录中的每个安装程序都能被 runInstaller 识别
+	for _, software := range createDefaultCatalog().Software {
+		switch ext := filepath.Ext(installerFileName(software, software.DownloadURL)); ext {
+		case ".msi", ".exe":
+		default:
+			t.Fatalf("%s: unsupported installer type %q", software.Name, ext)
+		}
+	}
+}
@@ -1,12 +1,44 @@
 package cmd
 
 import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+// testdataDir 是 cmd/testdata 的绝对路径，测试运行在临时目录中，不能使用相对路径
+var testdataDir string
+
+func TestMain(m *testing.M) {
+	// 测试中使用静默的 logger，并在临时目录中运行，避免 backup.orbit 等产物污染源码目录
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	sourceDir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	testdataDir = filepath.Join(sourceDir, "testdata")
+
+	workDir, err := os.MkdirTemp("", "orbit-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(workDir)
+	os.Exit(code)
+}
+
 func TestLoadFunc(t *testing.T) {
 	// 创建根命令
 	rootCmd := &cobra.Command{
@@ -19,7 +51,7 @@ func TestLoadFunc(t *testing.T) {
 		Short: "Save data",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// log.Println("\n[TEST] --------- 正在测试 'orbit save' 指令")
-			return createBackup()
+			return createBackup(context.Background())
 		},
 	}
 
@@ -96,3 +128,21 @@ func TestLoadFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestUnknownProfileAbortsCommand(t *testing.T) {
+	oldConfigPath, oldProfile, oldConfigManager := configFlagPath, profileName, globalConfigManager
+	defer func() {
+		configFlagPath, profileName, globalConfigManager = oldConfigPath, oldProfile, oldConfigManager
+		rootCmd.SetArgs(nil)
+	}()
+
+	os.Remove("backup.orbit")
+	configPath := filepath.Join(t.TempDir(), "info.json")
+	rootCmd.SetArgs([]string{"--config", configPath, "--profile", "nope", "save"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatal("save should fail when the profile does not exist")
+	}
+	if _, err := os.Stat("backup.orbit"); !os.IsNotExist(err) {
+		t.Fatal("save should not write a backup without a configuration")
+	}
+}
@@ -1,10 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
@@ -15,37 +15,145 @@ var configSetCmd = &cobra.Command{
 	Short: "Set configuration value",
 	Long: `Set configuration values for Orbit backup tool.
 
-Available configuration keys:
-- backup-path: Default backup directory path
-- encryption-enabled: Enable/disable encryption (true/false)
-- public-key-path: Path to public key file
-- private-key-path: Path to private key file
-- include-store-apps: Include Windows Store apps in software list (true/false)
-- auto-update-list: Automatically update software list (true/false)
-- backup-setting: Enable VSCode backup (true/false)
+Keys are the dotted JSON paths shown by 'orbit config list', e.g.
+encryption.public_key_path. The short names from earlier versions
+(backup-path, encryption-enabled, public-key-path, private-key-path,
+include-store-apps, auto-update-list, backup-setting) are still accepted.
+
+Booleans accept true/false, yes/no, on/off and 1/0. List values are
+comma separated and replace the whole list; use 'config add' and
+'config remove' to change single items.
 
 Examples:
   orbit config set backup-path "D:\backups"
-  orbit config set encryption-enabled true
-  orbit config set public-key-path "./my_public_key.pem"`,
+  orbit config set encryption.enabled true
+  orbit config set software.excluded_patterns "Mozilla*,Google Chrome"`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := args[0]
-		value := args[1]
+		configManager := requireConfigManager()
 
-		configManager := GetConfigManager()
-		if configManager == nil || !configManager.IsConfigLoaded() {
-			logger.Errorf("配置管理器未初始化")
-			return
+		key, err := setConfigValue(configManager, args[0], args[1])
+		if err != nil {
+			logger.Errorf("设置配置失败: %v", err)
+			os.Exit(1)
 		}
 
-		err := updateConfigValue(configManager, key, value)
+		logger.Infof("配置已更新: %s = %s", key.Key, key.Format(configManager.GetConfig()))
+	},
+}
+
+// configGetCmd 读取单个配置值
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print a configuration value",
+	Long: `Print the effective value of a configuration key to stdout.
+Lists are printed comma separated.
+
+Examples:
+  orbit config get encryption.public_key_path
+  orbit config get software.excluded_patterns`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		key, err := lookupConfigKey(args[0])
 		if err != nil {
-			logger.Errorf("设置配置失败: %v", err)
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(key.Format(configManager.GetConfig()))
+	},
+}
+
+// configUnsetCmd 恢复配置值
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Reset a configuration value",
+	Long: `Reset a configuration key. In the default profile the built-in default
+is restored; in other profiles the override is removed and the value is
+inherited from the base profile again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		key, err := unsetConfigValue(configManager, args[0])
+		if err != nil {
+			logger.Errorf("重置配置失败: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("配置已重置: %s = %s", key.Key, key.Format(configManager.GetConfig()))
+	},
+}
+
+// configListCmd 列出所有配置键
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all configuration keys",
+	Long:  `List every configuration key with its type and effective value.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+		config := configManager.GetConfig()
+
+		for _, key := range configKeyRegistry {
+			name := key.Key
+			if key.Alias != "" {
+				name += " (" + key.Alias + ")"
+			}
+			typeName := key.TypeName()
+			if key.ReadOnly {
+				typeName += ", readonly"
+			}
+			logger.Infof("  %-50s %-16s %s", name, typeName, key.Format(config))
+		}
+	},
+}
+
+// configAddCmd 向列表追加项
+var configAddCmd = &cobra.Command{
+	Use:   "add [key] [item...]",
+	Short: "Add items to a list value",
+	Long: `Add one or more items to a list-valued key. Items already present are skipped.
+
+Examples:
+  orbit config add software.excluded_patterns "Steam*" "Epic Games*"
+  orbit config add vscode.excluded_extensions ms-python.python`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		key, count, err := updateConfigList(configManager, args[0], args[1:], addListItems)
+		if err != nil {
+			logger.Errorf("修改配置失败: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("已向 %s 添加 %d 项: %s", key.Key, count, key.Format(configManager.GetConfig()))
+	},
+}
+
+// configRemoveCmd 从列表删除项
+var configRemoveCmd = &cobra.Command{
+	Use:   "remove [key] [item...]",
+	Short: "Remove items from a list value",
+	Long:  `Remove one or more items from a list-valued key.`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		key, count, err := updateConfigList(configManager, args[0], args[1:], removeListItems)
+		if err != nil {
+			logger.Errorf("修改配置失败: %v", err)
+			os.Exit(1)
+		}
+		if count == 0 {
+			logger.Warnf("%s 中没有找到要删除的项", key.Key)
 			return
 		}
 
-		logger.Infof("配置已更新: %s = %s", key, value)
+		logger.Infof("已从 %s 删除 %d 项: %s", key.Key, count, key.Format(configManager.GetConfig()))
 	},
 }
 
@@ -53,39 +161,90 @@ Examples:
 var configValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate current configuration",
-	Long:  `Validate the current configuration for errors and inconsistencies.`,
-	Args:  cobra.NoArgs,
+	Long: `Validate the effective configuration for errors and inconsistencies.
+
+Each issue has a field, a severity (error or warning), a code and a
+suggested fix. Issues with a known fix can be repaired with
+'orbit config repair [--code CODE]'. The command exits with status 1
+when any error is found.
+
+Examples:
+  orbit config validate
+  orbit config validate --format json`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		configManager := GetConfigManager()
-		if configManager == nil || !configManager.IsConfigLoaded() {
-			logger.Errorf("配置管理器未初始化")
-			return
-		}
+		configManager := requireConfigManager()
 
-		config := configManager.GetConfig()
-		if config == nil {
-			logger.Errorf("无法获取配置")
-			return
+		issues := validateUserConfig(configManager.GetConfig())
+		if err := printConfigIssues(issues, validateFormat); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(2)
 		}
 
-		issues := validateConfiguration(config)
-		if len(issues) == 0 {
-			logger.Info("配置验证通过 - 所有配置项都有效")
-		} else {
-			logger.Warnf("配置验证发现 %d 个问题:", len(issues))
-			for _, issue := range issues {
-				logger.Warnf("  - %s", issue)
-			}
+		if errCount, _ := countIssues(issues); errCount > 0 {
+			os.Exit(1)
 		}
 	},
 }
 
+// config validate 的输出格式
+var validateFormat string
+
+// printConfigIssues 以表格或 JSON 输出配置问题
+func printConfigIssues(issues []ConfigIssue, format string) error {
+	switch format {
+	case "json":
+		if issues == nil {
+			issues = []ConfigIssue{}
+		}
+		data, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "table", "":
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (可用 table、json)", format)
+	}
+
+	if len(issues) == 0 {
+		logger.Info("配置验证通过 - 所有配置项都有效")
+		return nil
+	}
+
+	errCount, warnCount := countIssues(issues)
+	logger.Warnf("配置验证发现 %d 个错误, %d 个警告:", errCount, warnCount)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tCODE\tFIELD\tMESSAGE\tFIX")
+	for _, issue := range issues {
+		fix := issue.Fix
+		if _, ok := configFixers[issue.Code]; ok && fix != "" {
+			fix += " (可自动修复)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", issue.Severity, issue.Code, issue.Field, issue.Message, fix)
+	}
+	return w.Flush()
+}
+
+// 是否显示每个配置值的来源
+var showConfigOrigin bool
+
 // configShowCmd 显示配置命令
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
-	Long:  `Display the current configuration values.`,
-	Args:  cobra.NoArgs,
+	Long: `Display the current configuration values.
+
+Values are resolved in this order, later sources taking precedence:
+defaults, the config file (and the selected profile), ORBIT_* environment
+variables, then command flags. Use --origin to see where each value came from.
+
+Examples:
+  orbit config show --origin
+  ORBIT_ENCRYPTION_ENABLED=false orbit config show --origin`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		configManager := GetConfigManager()
 		if configManager == nil || !configManager.IsConfigLoaded() {
@@ -99,7 +258,14 @@ var configShowCmd = &cobra.Command{
 			return
 		}
 
-		logger.Info("当前配置:")
+		if showConfigOrigin {
+			if err := printConfigOrigins(configManager, config); err != nil {
+				logger.Errorf("获取配置来源失败: %v", err)
+			}
+			return
+		}
+
+		logger.Infof("当前配置 (profile: %s):", configManager.GetProfileName())
 		logger.Infof("  系统配置:")
 		logger.Infof("    - 最后备份时间: %s", config.System.LastBackupTime)
 		logger.Infof("    - 备份次数: %d", config.System.BackupCount)
@@ -125,29 +291,48 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+// config repair 只修复这些问题代码
+var repairCodes []string
+
 // configRepairCmd 配置修复命令
 var configRepairCmd = &cobra.Command{
 	Use:   "repair",
 	Short: "Repair configuration issues",
-	Long:  `Automatically repair common configuration issues.`,
-	Args:  cobra.NoArgs,
+	Long: `Automatically repair configuration issues reported by 'orbit config validate'.
+
+By default every issue with a known fix is repaired. Use --code to limit
+the repair to specific issue codes.
+
+Examples:
+  orbit config repair
+  orbit config repair --code encryption_public_key_missing --code backup_path_missing`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		configManager := GetConfigManager()
-		if configManager == nil || !configManager.IsConfigLoaded() {
-			logger.Errorf("配置管理器未初始化")
-			return
+		configManager := requireConfigManager()
+
+		for _, code := range repairCodes {
+			if _, ok := configFixers[code]; !ok {
+				logger.Errorf("未知或无法自动修复的问题代码: %s", code)
+				os.Exit(1)
+			}
 		}
 
-		repairCount, err := repairConfiguration(configManager)
+		fixed, remaining, err := repairConfiguration(configManager, repairCodes)
 		if err != nil {
 			logger.Errorf("配置修复失败: %v", err)
-			return
+			os.Exit(1)
 		}
 
-		if repairCount == 0 {
+		if len(fixed) == 0 {
 			logger.Info("配置修复完成 - 未发现需要修复的问题")
 		} else {
-			logger.Infof("配置修复完成 - 修复了 %d 个问题", repairCount)
+			logger.Infof("配置修复完成 - 修复了 %d 个问题", len(fixed))
+		}
+		if len(remaining) > 0 {
+			logger.Warnf("仍有 %d 个问题需要手动处理:", len(remaining))
+			for _, issue := range remaining {
+				logger.Warnf("  [%s] %s: %s", issue.Code, issue.Field, issue.Message)
+			}
 		}
 	},
 }
@@ -163,140 +348,101 @@ var configCmd = &cobra.Command{
 	},
 }
 
-// updateConfigValue 更新配置值
-func updateConfigValue(configManager *ConfigManager, key string, value string) error {
-	return configManager.UpdateConfig(func(config *UserConfig) {
-		switch strings.ToLower(key) {
-		case "backup-path":
-			config.System.DefaultBackupPath = value
-		case "encryption-enabled":
-			config.Encryption.Enabled = (value == "true" || value == "1" || value == "yes")
-		case "public-key-path":
-			config.Encryption.PublicKeyPath = value
-		case "private-key-path":
-			config.Encryption.PrivateKeyPath = value
-		case "include-store-apps":
-			config.Software.IncludeStoreApps = (value == "true" || value == "1" || value == "yes")
-		case "auto-update-list":
-			config.Software.AutoUpdateList = (value == "true" || value == "1" || value == "yes")
-		case "backup-setting":
-			config.VSCode.BackupSetting = (value == "true" || value == "1" || value == "yes")
-		default:
-			logger.Warnf("未知的配置键: %s", key)
-		}
-	})
-}
-
-// validateConfiguration 验证配置
-func validateConfiguration(config *UserConfig) []string {
-	var issues []string
-
-	// 验证系统配置
-	if config.System.DefaultBackupPath == "" {
-		issues = append(issues, "默认备份路径为空")
-	} else if _, err := os.Stat(config.System.DefaultBackupPath); os.IsNotExist(err) {
-		issues = append(issues, fmt.Sprintf("默认备份路径不存在: %s", config.System.DefaultBackupPath))
+// printConfigOrigins 逐项打印有效配置值及其来源
+func printConfigOrigins(configManager *ConfigManager, config *UserConfig) error {
+	origins, err := configManager.ConfigOrigins()
+	if err != nil {
+		return err
+	}
+	values, err := configToValues(config)
+	if err != nil {
+		return err
 	}
 
-	// 验证VSCode配置
-	if len(config.VSCode.ConfigDirs) == 0 {
-		issues = append(issues, "VSCode配置目录为空")
-	} else {
-		for _, dir := range config.VSCode.ConfigDirs {
-			if _, err := os.Stat(dir.Path); os.IsNotExist(err) {
-				issues = append(issues, fmt.Sprintf("VSCode配置目录不存在: %s", dir.Path))
-			}
-		}
+	logger.Infof("当前配置 (profile: %s):", configManager.GetProfileName())
+	for _, key := range configKeyRegistry {
+		value, _ := lookupValue(values, key.Key)
+		logger.Infof("  %-34s = %-40s [%s]", key.Key, formatConfigValue(value), origins[key.Key])
 	}
+	return nil
+}
 
-	// 验证加密配置
-	if config.Encryption.Enabled {
-		if config.Encryption.PublicKeyPath == "" {
-			issues = append(issues, "启用加密但公钥路径为空")
-		} else if _, err := os.Stat(config.Encryption.PublicKeyPath); os.IsNotExist(err) {
-			issues = append(issues, fmt.Sprintf("公钥文件不存在: %s", config.Encryption.PublicKeyPath))
-		}
+// requireConfigManager 返回已加载的全局配置管理器，未初始化时退出
+func requireConfigManager() *ConfigManager {
+	configManager := GetConfigManager()
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		logger.Errorf("配置管理器未初始化")
+		os.Exit(1)
+	}
+	return configManager
+}
 
-		if config.Encryption.PrivateKeyPath == "" {
-			issues = append(issues, "启用加密但私钥路径为空")
-		} else if _, err := os.Stat(config.Encryption.PrivateKeyPath); os.IsNotExist(err) {
-			issues = append(issues, fmt.Sprintf("私钥文件不存在: %s", config.Encryption.PrivateKeyPath))
-		}
+// setConfigValue 解析 raw 并设置当前 profile 中的配置键
+func setConfigValue(configManager *ConfigManager, name, raw string) (configKey, error) {
+	key, err := lookupWritableConfigKey(name)
+	if err != nil {
+		return key, err
+	}
+	value, err := key.Parse(raw)
+	if err != nil {
+		return key, err
 	}
 
-	return issues
+	return key, configManager.updateProfile(func(config, parent *UserConfig) error {
+		key.Set(config, value)
+		return nil
+	})
 }
 
-// repairConfiguration 修复配置问题
-func repairConfiguration(configManager *ConfigManager) (int, error) {
-	repairCount := 0
-
-	err := configManager.UpdateConfig(func(config *UserConfig) {
-		// 修复默认备份路径
-		if config.System.DefaultBackupPath == "" {
-			config.System.DefaultBackupPath = CurrentDir
-			repairCount++
-			logger.Info("修复: 设置默认备份路径为当前目录")
-		}
+// unsetConfigValue 把配置键恢复为继承的值
+func unsetConfigValue(configManager *ConfigManager, name string) (configKey, error) {
+	key, err := lookupWritableConfigKey(name)
+	if err != nil {
+		return key, err
+	}
 
-		// 修复VSCode配置目录
-		if len(config.VSCode.ConfigDirs) == 0 {
-			config.VSCode.ConfigDirs = []ConfigDirType{
-				{
-					Name:         "APPDATA",
-					Path:         CodeConfigDir,
-					OriginalPath: "%APPDATA%\\Code",
-				},
-				{
-					Name:         "USER",
-					Path:         CodeUserDir,
-					OriginalPath: "%USERPROFILE%\\.vscode",
-				},
-			}
-			repairCount++
-			logger.Info("修复: 重新设置VSCode配置目录")
-		}
+	return key, configManager.updateProfile(func(config, parent *UserConfig) error {
+		key.Set(config, key.Get(parent))
+		return nil
+	})
+}
 
-		// 修复加密配置不一致
-		if config.Encryption.Enabled && (config.Encryption.PublicKeyPath == "" || config.Encryption.PrivateKeyPath == "") {
-			config.Encryption.Enabled = false
-			repairCount++
-			logger.Warn("修复: 禁用加密（密钥路径缺失）")
-		}
+// updateConfigList 用 op（addListItems 或 removeListItems）修改列表配置，返回变化的项数
+func updateConfigList(configManager *ConfigManager, name string, items []string, op func([]string, []string) ([]string, int)) (configKey, int, error) {
+	key, err := lookupWritableConfigKey(name)
+	if err != nil {
+		return key, 0, err
+	}
+	if !key.IsList() {
+		return key, 0, fmt.Errorf("%s 不是列表类型 (%s)，请使用 config set", key.Key, key.TypeName())
+	}
 
-		// 修复密钥文件路径
-		if config.Encryption.Enabled {
-			// 检查公钥文件是否存在
-			if _, err := os.Stat(config.Encryption.PublicKeyPath); os.IsNotExist(err) {
-				// 尝试在当前目录查找
-				possiblePaths := []string{
-					filepath.Join(CurrentDir, "public.pem"),
-					filepath.Join(CurrentDir, getWinUserName()+"_public_key.pem"),
-					filepath.Join(filepath.Dir(configManager.configPath), "keys", "public.pem"),
-				}
-
-				for _, path := range possiblePaths {
-					if _, err := os.Stat(path); err == nil {
-						config.Encryption.PublicKeyPath = path
-						repairCount++
-						logger.Infof("修复: 找到公钥文件 %s", path)
-						break
-					}
-				}
-			}
-		}
+	count := 0
+	err = configManager.updateProfile(func(config, parent *UserConfig) error {
+		list, changed := op(key.Get(config).([]string), items)
+		key.Set(config, list)
+		count = changed
+		return nil
 	})
-
-	return repairCount, err
+	return key, count, err
 }
 
 func init() {
 	// 添加子命令到配置主命令
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configAddCmd)
+	configCmd.AddCommand(configRemoveCmd)
 	configCmd.AddCommand(configValidateCmd)
 	configCmd.AddCommand(configShowCmd)
 	configCmd.AddCommand(configRepairCmd)
 
+	configValidateCmd.Flags().StringVar(&validateFormat, "format", "table", "Output format: table or json")
+	configRepairCmd.Flags().StringArrayVar(&repairCodes, "code", nil, "Only repair issues with this code (repeatable)")
+	configShowCmd.Flags().BoolVar(&showConfigOrigin, "origin", false, "Show where each effective value came from")
+
 	// 添加配置命令到根命令
 	rootCmd.AddCommand(configCmd)
 }
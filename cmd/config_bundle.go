@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ConfigBundleFormat 标识 orbit config export 生成的文件
+const ConfigBundleFormat = "orbit-config-bundle"
+
+// ConfigBundleFileName 是嵌入在 .orbit 备份中的配置文件名
+const ConfigBundleFileName = "orbit-config.json"
+
+// 恢复时是否导入备份中嵌入的 Orbit 配置
+var restoreImportConfig bool
+
+// configBundle 是可在不同机器之间迁移的配置文件，本机路径已替换为占位符
+type configBundle struct {
+	Format       string          `json:"format"`
+	OrbitVersion string          `json:"orbit_version"`
+	ExportedAt   string          `json:"exported_at"`
+	Config       json.RawMessage `json:"config"`
+}
+
+// portableRoot 是一个可替换为占位符的本机目录
+type portableRoot struct {
+	Placeholder string
+	Dir         string
+}
+
+// portableRoots 返回导出时识别的本机目录，越具体的目录越靠前
+func portableRoots(configPath string) []portableRoot {
+	var roots []portableRoot
+	add := func(name, dir string) {
+		if dir != "" {
+			roots = append(roots, portableRoot{Placeholder: "${" + name + "}", Dir: filepath.Clean(dir)})
+		}
+	}
+
+	add("ORBIT_HOME", filepath.Dir(configPath))
+	add("APPDATA", os.Getenv("APPDATA"))
+	home, _ := os.UserHomeDir()
+	add("HOME", home)
+	return roots
+}
+
+// hasPathPrefix 判断 path 是否位于 dir 之下，Windows 上不区分大小写
+func hasPathPrefix(path, dir string) bool {
+	if len(path) < len(dir) {
+		return false
+	}
+	head := path[:len(dir)]
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(head, dir) {
+			return false
+		}
+	} else if head != dir {
+		return false
+	}
+	return len(path) == len(dir) || os.IsPathSeparator(path[len(dir)])
+}
+
+// toPortablePath 把本机绝对路径替换为 ${ORBIT_HOME}/keys/public.pem 这样的形式
+func toPortablePath(path string, roots []portableRoot) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	cleaned := filepath.Clean(path)
+	for _, root := range roots {
+		if hasPathPrefix(cleaned, root.Dir) {
+			rest := filepath.ToSlash(cleaned[len(root.Dir):])
+			return root.Placeholder + rest
+		}
+	}
+	return path
+}
+
+// expandPortablePath 把占位符展开为本机目录
+func expandPortablePath(path string, roots []portableRoot) string {
+	for _, root := range roots {
+		if strings.HasPrefix(path, root.Placeholder) {
+			return filepath.Join(root.Dir, filepath.FromSlash(path[len(root.Placeholder):]))
+		}
+	}
+	return path
+}
+
+// rewriteConfigPaths 对配置（包括各 profile 的覆盖值）中的所有路径调用 rewrite
+func rewriteConfigPaths(config *UserConfig, rewrite func(string) string) {
+	for _, key := range configKeyRegistry {
+		if !key.Path {
+			continue
+		}
+		key.Set(config, rewrite(key.Get(config).(string)))
+
+		for _, profile := range config.Profiles {
+			if value, ok := lookupValue(profile.Values, key.Key); ok {
+				if path, ok := value.(string); ok {
+					setValue(profile.Values, key.Key, rewrite(path))
+				}
+			}
+		}
+	}
+
+	for i := range config.VSCode.ConfigDirs {
+		config.VSCode.ConfigDirs[i].Path = rewrite(config.VSCode.ConfigDirs[i].Path)
+		config.VSCode.ConfigDirs[i].OriginalPath = rewrite(config.VSCode.ConfigDirs[i].OriginalPath)
+	}
+}
+
+// resetReadOnlyValues 清除由 Orbit 维护的状态字段（包括旧版本写入 profile 的值），
+// 这些值只对本机有意义
+func resetReadOnlyValues(config *UserConfig) {
+	for _, key := range configKeyRegistry {
+		if !key.ReadOnly {
+			continue
+		}
+		key.Set(config, reflect.Zero(reflect.TypeOf(key.Get(config))).Interface())
+	}
+	for _, profile := range config.Profiles {
+		deleteReadOnlyValues(profile.Values)
+	}
+}
+
+// exportConfigBundle 生成当前配置（包含所有 profile）的可移植副本
+func exportConfigBundle(configManager *ConfigManager, exportedAt time.Time) ([]byte, error) {
+	root := configManager.GetRootConfig()
+	if root == nil {
+		return nil, fmt.Errorf("配置未加载")
+	}
+
+	roots := portableRoots(configManager.configPath)
+	rewriteConfigPaths(root, func(path string) string {
+		return toPortablePath(path, roots)
+	})
+	resetReadOnlyValues(root)
+	// 修改时间只对本机有意义，清除后 save --reproducible 的输出才能逐字节相同
+	root.LastUpdate = ""
+
+	configData, err := json.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %w", err)
+	}
+
+	bundle := configBundle{
+		Format:       ConfigBundleFormat,
+		OrbitVersion: Version,
+		ExportedAt:   exportedAt.Format(time.RFC3339),
+		Config:       configData,
+	}
+	return json.MarshalIndent(bundle, "", "  ")
+}
+
+// importConfigBundle 展开占位符并用导入的配置替换当前配置，本机的备份统计保持不变
+func importConfigBundle(configManager *ConfigManager, data []byte) (*UserConfig, error) {
+	var bundle configBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if bundle.Format != ConfigBundleFormat || len(bundle.Config) == 0 {
+		return nil, fmt.Errorf("不是 Orbit 配置导出文件")
+	}
+
+	// 旧版本导出的配置同样需要迁移
+	configData, fromVersion, err := migrateConfigData(bundle.Config, forceConfigLoad)
+	if err != nil {
+		return nil, err
+	}
+	var imported UserConfig
+	if err := json.Unmarshal(configData, &imported); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if fromVersion <= CurrentConfigSchemaVersion {
+		imported.SchemaVersion = CurrentConfigSchemaVersion
+	}
+
+	roots := portableRoots(configManager.configPath)
+	rewriteConfigPaths(&imported, func(path string) string {
+		return expandPortablePath(path, roots)
+	})
+
+	// 导入前保留一份原配置
+	if previous, err := os.ReadFile(configManager.configPath); err == nil {
+		if err := writeFileAtomic(configManager.configPath+".pre-import.bak", previous, 0644); err != nil {
+			logger.Warnf("备份原配置失败: %v", err)
+		}
+	}
+
+	err = configManager.UpdateRootConfig(func(root *UserConfig) error {
+		for _, key := range configKeyRegistry {
+			if key.ReadOnly {
+				key.Set(&imported, key.Get(root))
+			}
+		}
+		for _, profile := range imported.Profiles {
+			deleteReadOnlyValues(profile.Values)
+		}
+		*root = imported
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configManager.GetConfig(), nil
+}
+
+// warnMissingKeyFiles 导入后提示需要手动复制的密钥文件
+func warnMissingKeyFiles(config *UserConfig) {
+	if !config.Encryption.Enabled {
+		return
+	}
+	for _, path := range []string{config.Encryption.PublicKeyPath, config.Encryption.PrivateKeyPath} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			logger.Warnf("密钥文件不存在: %s，请把原机器上的密钥复制到该位置或运行 orbit gen-keys", path)
+		}
+	}
+}
+
+// configBundleProvider 把可移植的 Orbit 配置写入备份，供 restore --import-config 使用
+type configBundleProvider struct {
+	opts backupOptions
+}
+
+func (configBundleProvider) Name() string { return ConfigBundleFileName }
+
+func (p configBundleProvider) Collect(ctx context.Context, stage *backupStaging, set *entrySet) error {
+	configManager := GetConfigManager()
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		logger.Warnf("配置管理器未初始化，备份中不包含 Orbit 配置")
+		return nil
+	}
+
+	data, err := exportConfigBundle(configManager, p.opts.now())
+	if err != nil {
+		return err
+	}
+	set.AddData(ConfigBundleFileName, data, p.opts.now())
+	return nil
+}
+
+// restoreEmbeddedConfig 导入恢复目录中的 orbit-config.json
+func restoreEmbeddedConfig(tempDir string) error {
+	bundlePath := filepath.Join(tempDir, ConfigBundleFileName)
+	data, err := os.ReadFile(bundlePath)
+	if os.IsNotExist(err) {
+		if restoreImportConfig {
+			logger.Warnf("备份中不包含 Orbit 配置 (保存时未使用 --include-config)")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !restoreImportConfig {
+		logger.Infof("备份中包含 Orbit 配置，使用 --import-config 可一并导入")
+		return nil
+	}
+
+	configManager := GetConfigManager()
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		return fmt.Errorf("配置管理器未初始化")
+	}
+	config, err := importConfigBundle(configManager, data)
+	if err != nil {
+		return err
+	}
+	logger.Infof("已从备份导入 Orbit 配置")
+	warnMissingKeyFiles(config)
+	return nil
+}
+
+// configExportCmd 导出配置
+var configExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export configuration to a portable file",
+	Long: `Export the configuration, including all profiles, to a portable file.
+
+Absolute paths under the Orbit config directory, %APPDATA% and the user's
+home directory are written as ${ORBIT_HOME}, ${APPDATA} and ${HOME}
+placeholders, and backup statistics are left out. Key files are not
+exported; copy them separately.
+
+Examples:
+  orbit config export orbit-config.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		data, err := exportConfigBundle(configManager, time.Now())
+		if err != nil {
+			logger.Errorf("导出配置失败: %v", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(args[0], data, 0644); err != nil {
+			logger.Errorf("写入导出文件失败: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("配置已导出到 %s", args[0])
+	},
+}
+
+// configImportCmd 导入配置
+var configImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import configuration from an exported file",
+	Long: `Replace the current configuration with one written by 'orbit config export'.
+
+Placeholders are expanded for this machine. The previous configuration is
+kept as info.json.pre-import.bak.
+
+Examples:
+  orbit config import orbit-config.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			logger.Errorf("读取导入文件失败: %v", err)
+			os.Exit(1)
+		}
+		config, err := importConfigBundle(configManager, data)
+		if err != nil {
+			logger.Errorf("导入配置失败: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("配置已从 %s 导入", args[0])
+		warnMissingKeyFiles(config)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configExportCmd)
+	configCmd.AddCommand(configImportCmd)
+}
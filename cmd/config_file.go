@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileLock 是跨进程的建议锁，锁住的是单独的 .lock 文件
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile 获取 path+".lock" 上的独占锁，阻塞直到成功
+func lockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := lockFileHandle(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("获取文件锁失败: %w", err)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// Unlock 释放锁
+func (l *fileLock) Unlock() {
+	if l == nil || l.f == nil {
+		return
+	}
+	unlockFileHandle(l.f)
+	l.f.Close()
+	l.f = nil
+}
+
+// writeFileAtomic 先写入同目录下的临时文件并 fsync，再重命名覆盖目标文件，
+// 进程在任何时刻崩溃都不会留下只写了一半的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 同步目录，确保重命名本身落盘（Windows 上打开目录会失败，忽略即可）
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// lastGoodConfigPath 返回最近一次有效配置的备份路径
+func lastGoodConfigPath(configPath string) string {
+	return configPath + ".bak"
+}
@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ConfigHistoryFileName 是配置修改日志的文件名，与 info.json 位于同一目录
+const ConfigHistoryFileName = "history.jsonl"
+
+// configValueChange 是一次修改中单个键的变化，Old/New 为 nil 表示该键不存在
+type configValueChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// configHistoryEntry 是修改日志中的一行，对应一次配置写入
+type configHistoryEntry struct {
+	ID        int                 `json:"id"`
+	Timestamp string              `json:"timestamp"`
+	Command   string              `json:"command,omitempty"`
+	Changes   []configValueChange `json:"changes"`
+	Reverts   []int               `json:"reverts,omitempty"` // undo 记录撤销了哪些修改
+}
+
+// historyPath 返回修改日志的路径
+func (cm *ConfigManager) historyPath() string {
+	return filepath.Join(filepath.Dir(cm.configPath), ConfigHistoryFileName)
+}
+
+// SetCommand 设置写入修改日志的命令行
+func (cm *ConfigManager) SetCommand(command string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.command = command
+}
+
+// flattenConfig 把完整配置（包括 profile）展开为 点分路径 -> 值，列表作为一个整体。
+// 由 Orbit 维护的只读状态（例如 system.backup_count）不是用户的修改，不包括在内。
+func flattenConfig(config *UserConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	delete(raw, "last_update")
+
+	flat := make(map[string]interface{})
+	var walk func(prefix string, values map[string]interface{})
+	walk = func(prefix string, values map[string]interface{}) {
+		for key, value := range values {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(path, nested)
+				continue
+			}
+			if value != nil {
+				flat[path] = value
+			}
+		}
+	}
+	walk("", raw)
+
+	for path := range flat {
+		if isReadOnlyConfigPath(path) {
+			delete(flat, path)
+		}
+	}
+	return flat, nil
+}
+
+// isReadOnlyConfigPath 判断展开后的路径是否为只读状态键，包括旧版本写入 profile 的
+// profiles.<名称>.values.<键>
+func isReadOnlyConfigPath(path string) bool {
+	if strings.HasPrefix(path, "profiles.") {
+		if i := strings.Index(path, ".values."); i >= 0 {
+			path = path[i+len(".values."):]
+		}
+	}
+	for _, key := range configKeyRegistry {
+		if key.ReadOnly && key.Key == path {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConfigs 返回从 before 到 after 的所有键变化，按键排序
+func diffConfigs(before, after *UserConfig) ([]configValueChange, error) {
+	oldValues, err := flattenConfig(before)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := flattenConfig(after)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for key := range oldValues {
+		keys[key] = true
+	}
+	for key := range newValues {
+		keys[key] = true
+	}
+
+	var changes []configValueChange
+	for key := range keys {
+		if !reflect.DeepEqual(oldValues[key], newValues[key]) {
+			changes = append(changes, configValueChange{Key: key, Old: oldValues[key], New: newValues[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// readConfigHistory 读取修改日志，日志不存在时返回空列表；末尾写了一半的行会被忽略
+func readConfigHistory(path string) ([]configHistoryEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []configHistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry configHistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			logger.Warnf("忽略无法解析的配置历史记录: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// appendConfigHistory 在持有配置文件锁时追加一条修改记录
+func (cm *ConfigManager) appendConfigHistory(before, after *UserConfig, reverts []int) error {
+	changes, err := diffConfigs(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && len(reverts) == 0 {
+		return nil
+	}
+
+	entries, err := readConfigHistory(cm.historyPath())
+	if err != nil {
+		return err
+	}
+	entry := configHistoryEntry{
+		ID:        1,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Command:   cm.command,
+		Changes:   changes,
+		Reverts:   reverts,
+	}
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cm.historyPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// undoableEntries 返回尚未被撤销的普通修改记录（不含 undo 记录本身），按时间顺序
+func undoableEntries(entries []configHistoryEntry) []configHistoryEntry {
+	reverted := make(map[int]bool)
+	for _, entry := range entries {
+		for _, id := range entry.Reverts {
+			reverted[id] = true
+		}
+	}
+
+	var result []configHistoryEntry
+	for _, entry := range entries {
+		if len(entry.Reverts) > 0 || reverted[entry.ID] {
+			continue
+		}
+		// 旧版本记录的只读状态变化不撤销，只剩这些变化的记录整条跳过
+		entry.Changes = userChanges(entry.Changes)
+		if len(entry.Changes) > 0 {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// userChanges 去掉只读状态键的变化
+func userChanges(changes []configValueChange) []configValueChange {
+	var result []configValueChange
+	for _, change := range changes {
+		if isReadOnlyConfigPath(change.Key) {
+			continue
+		}
+		result = append(result, change)
+	}
+	return result
+}
+
+// revertChanges 在配置的 JSON 表示上把 changes 恢复为旧值
+func revertChanges(config *UserConfig, changes []configValueChange) (*UserConfig, []string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	current, err := flattenConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conflicts []string
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		if !reflect.DeepEqual(current[change.Key], change.New) {
+			conflicts = append(conflicts, change.Key)
+		}
+		if change.Old == nil {
+			deleteValue(raw, change.Key)
+		} else {
+			setValue(raw, change.Key, change.Old)
+		}
+	}
+
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reverted UserConfig
+	if err := json.Unmarshal(data, &reverted); err != nil {
+		return nil, nil, err
+	}
+	return &reverted, conflicts, nil
+}
+
+// deleteValue 按点分路径删除嵌套 map 中的值
+func deleteValue(values map[string]interface{}, key string) {
+	parts := strings.Split(key, ".")
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
+
+// undoConfigChanges 撤销最近 n 次尚未撤销的修改，返回被撤销的记录
+func undoConfigChanges(configManager *ConfigManager, n int) ([]configHistoryEntry, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("撤销次数必须大于 0")
+	}
+
+	entries, err := readConfigHistory(configManager.historyPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取配置历史失败: %w", err)
+	}
+	candidates := undoableEntries(entries)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("没有可以撤销的配置修改")
+	}
+	if n > len(candidates) {
+		return nil, fmt.Errorf("只有 %d 次修改可以撤销", len(candidates))
+	}
+	targets := candidates[len(candidates)-n:]
+
+	ids := make([]int, 0, n)
+	for _, entry := range targets {
+		ids = append(ids, entry.ID)
+	}
+
+	err = configManager.commitRoot(func(root *UserConfig) error {
+		// 从最近的一次开始逐条撤销
+		for i := len(targets) - 1; i >= 0; i-- {
+			reverted, conflicts, err := revertChanges(root, targets[i].Changes)
+			if err != nil {
+				return err
+			}
+			if len(conflicts) > 0 {
+				logger.Warnf("修改 #%d 之后以下配置又被修改过，将恢复为 #%d 之前的值: %s",
+					targets[i].ID, targets[i].ID, strings.Join(conflicts, ", "))
+			}
+			*root = *reverted
+		}
+		return nil
+	}, ids)
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// formatHistoryValue 格式化修改日志中的值
+func formatHistoryValue(value interface{}) string {
+	if value == nil {
+		return "(未设置)"
+	}
+	return formatConfigValue(value)
+}
+
+// 显示的历史记录条数
+var historyLimit int
+
+// configHistoryCmd 显示配置修改历史
+var configHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show configuration change history",
+	Long: `Show the journal of configuration changes, newest last.
+
+Every write to info.json is recorded with the time, the command that made
+it and the old and new value of every changed key. Changes that have been
+reverted with 'orbit config undo' are marked.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		entries, err := readConfigHistory(configManager.historyPath())
+		if err != nil {
+			logger.Errorf("读取配置历史失败: %v", err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 {
+			logger.Info("没有配置修改记录")
+			return
+		}
+
+		reverted := make(map[int]int)
+		for _, entry := range entries {
+			for _, id := range entry.Reverts {
+				reverted[id] = entry.ID
+			}
+		}
+
+		if historyLimit > 0 && len(entries) > historyLimit {
+			entries = entries[len(entries)-historyLimit:]
+		}
+		for _, entry := range entries {
+			title := fmt.Sprintf("#%d  %s  %s", entry.ID, entry.Timestamp, entry.Command)
+			if len(entry.Reverts) > 0 {
+				title += fmt.Sprintf("  (撤销 %v)", entry.Reverts)
+			}
+			if by, ok := reverted[entry.ID]; ok {
+				title += fmt.Sprintf("  [已被 #%d 撤销]", by)
+			}
+			logger.Info(title)
+			for _, change := range entry.Changes {
+				logger.Infof("    %s: %s -> %s", change.Key, formatHistoryValue(change.Old), formatHistoryValue(change.New))
+			}
+		}
+	},
+}
+
+// configUndoCmd 撤销配置修改
+var configUndoCmd = &cobra.Command{
+	Use:   "undo [n]",
+	Short: "Revert the last n configuration changes",
+	Long: `Revert the most recent n configuration changes (default 1) that have not
+been reverted yet. The undo itself is recorded in the history.
+
+Examples:
+  orbit config undo
+  orbit config undo 3`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		n := 1
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				logger.Errorf("无效的撤销次数: %s", args[0])
+				os.Exit(1)
+			}
+		}
+
+		undone, err := undoConfigChanges(configManager, n)
+		if err != nil {
+			logger.Errorf("撤销失败: %v", err)
+			os.Exit(1)
+		}
+		for i := len(undone) - 1; i >= 0; i-- {
+			logger.Infof("已撤销 #%d  %s  %s", undone[i].ID, undone[i].Timestamp, undone[i].Command)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configHistoryCmd)
+	configCmd.AddCommand(configUndoCmd)
+
+	configHistoryCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of entries to show (0 for all)")
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// configKey 是配置键注册表中的一项，根据 UserConfig 的 json 和 config 标签生成。
+//
+// config 标签格式为 `config:"别名,选项..."`：别名是兼容旧版 config set 的短名称；
+// 选项 readonly 表示由 Orbit 自己维护的状态字段，不能通过命令或环境变量修改，
+// 选项 path 表示值是本机路径，导出时会替换为可移植的占位符。
+type configKey struct {
+	Key      string // 使用 json 名称的点分路径，例如 encryption.public_key_path
+	Alias    string // 旧版短名称，例如 public-key-path
+	Kind     reflect.Kind
+	ReadOnly bool
+	Path     bool
+	index    []int
+}
+
+// configKeyRegistry 按 UserConfig 中字段的顺序列出所有配置键
+var configKeyRegistry = buildConfigKeyRegistry()
+
+func buildConfigKeyRegistry() []configKey {
+	var keys []configKey
+	collectConfigKeys(reflect.TypeOf(UserConfig{}), "", nil, &keys)
+	return keys
+}
+
+// collectConfigKeys 递归收集字符串、布尔、整数和字符串列表字段
+func collectConfigKeys(t reflect.Type, prefix string, index []int, keys *[]configKey) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if prefix == "" && isProfileMetaKey(name) {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		kind := field.Type.Kind()
+		switch {
+		case kind == reflect.Struct:
+			collectConfigKeys(field.Type, key, fieldIndex, keys)
+			continue
+		case kind == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+		case kind == reflect.String, kind == reflect.Bool, kind == reflect.Int:
+		default:
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("config"), ",")
+		entry := configKey{Key: key, Alias: tag[0], Kind: kind, index: fieldIndex}
+		for _, option := range tag[1:] {
+			switch option {
+			case "readonly":
+				entry.ReadOnly = true
+			case "path":
+				entry.Path = true
+			}
+		}
+		*keys = append(*keys, entry)
+	}
+}
+
+// isProfileMetaKey 判断顶层字段是否为不参与 profile 和覆盖的元数据
+func isProfileMetaKey(key string) bool {
+	for _, meta := range profileMetaKeys {
+		if key == meta {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupConfigKey 按点分路径或别名查找配置键，不区分大小写
+func lookupConfigKey(name string) (configKey, error) {
+	for _, key := range configKeyRegistry {
+		if strings.EqualFold(key.Key, name) || (key.Alias != "" && strings.EqualFold(key.Alias, name)) {
+			return key, nil
+		}
+	}
+	return configKey{}, fmt.Errorf("未知的配置键: %s (使用 orbit config list 查看所有配置键)", name)
+}
+
+// lookupWritableConfigKey 查找可以修改的配置键
+func lookupWritableConfigKey(name string) (configKey, error) {
+	key, err := lookupConfigKey(name)
+	if err != nil {
+		return key, err
+	}
+	if key.ReadOnly {
+		return key, fmt.Errorf("配置键 %s 由 Orbit 自动维护，不能手动修改", key.Key)
+	}
+	return key, nil
+}
+
+// EnvName 返回配置键对应的环境变量名
+func (k configKey) EnvName() string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(k.Key, ".", "_"))
+}
+
+// TypeName 返回用于显示的类型名称
+func (k configKey) TypeName() string {
+	switch k.Kind {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int:
+		return "int"
+	case reflect.Slice:
+		return "list"
+	}
+	return "string"
+}
+
+// IsList 判断配置键是否为列表
+func (k configKey) IsList() bool {
+	return k.Kind == reflect.Slice
+}
+
+// Parse 把字符串解析为配置键类型对应的值，列表使用逗号分隔
+func (k configKey) Parse(raw string) (interface{}, error) {
+	switch k.Kind {
+	case reflect.Bool:
+		return parseConfigBool(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s 需要整数，得到 %q", k.Key, raw)
+		}
+		return n, nil
+	case reflect.Slice:
+		return splitConfigList(raw), nil
+	}
+	return raw, nil
+}
+
+// Get 读取配置中该键的值
+func (k configKey) Get(config *UserConfig) interface{} {
+	return reflect.ValueOf(config).Elem().FieldByIndex(k.index).Interface()
+}
+
+// Set 把 Parse 返回的值写入配置
+func (k configKey) Set(config *UserConfig, value interface{}) {
+	reflect.ValueOf(config).Elem().FieldByIndex(k.index).Set(reflect.ValueOf(value))
+}
+
+// Format 把配置中该键的值格式化为字符串
+func (k configKey) Format(config *UserConfig) string {
+	switch v := k.Get(config).(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseConfigBool 解析布尔值，兼容 yes/no、on/off，其他值返回错误
+func parseConfigBool(raw string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "true", "1", "yes", "on":
+		return true, nil
+	case "false", "0", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的布尔值 %q (可用 true/false、yes/no、on/off、1/0)", raw)
+}
+
+// splitConfigList 按逗号切分列表值，忽略空项
+func splitConfigList(raw string) []string {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// addListItems 把 items 中尚不存在的项追加到列表末尾，返回新增的数量
+func addListItems(list []string, items []string) ([]string, int) {
+	added := 0
+	for _, item := range items {
+		exists := false
+		for _, existing := range list {
+			if existing == item {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, item)
+			added++
+		}
+	}
+	return list, added
+}
+
+// removeListItems 从列表中删除 items，返回删除的数量
+func removeListItems(list []string, items []string) ([]string, int) {
+	result := []string{}
+	removed := 0
+	for _, existing := range list {
+		matched := false
+		for _, item := range items {
+			if existing == item {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			removed++
+			continue
+		}
+		result = append(result, existing)
+	}
+	return result, removed
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// 配置值的来源，按优先级从低到高排列
+const (
+	originDefault = "default"
+	originFile    = "file"
+)
+
+// ORBIT_* 环境变量的前缀，例如 ORBIT_ENCRYPTION_PUBLIC_KEY_PATH
+const configEnvPrefix = "ORBIT_"
+
+// 当前命令使用的环境变量和命令行参数覆盖，由 initOrbit_user 设置
+var configOverrides []configOverride
+
+// configOverride 是来自环境变量或命令行参数的一项配置覆盖，只影响本次运行，不会写入 info.json
+type configOverride struct {
+	Key    string
+	Value  string
+	Origin string
+}
+
+// lookupValue 按点分路径读取嵌套 map 中的值
+func lookupValue(values map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[parts[len(parts)-1]]
+	return value, ok
+}
+
+// setValue 按点分路径写入嵌套 map，缺少的中间对象会被创建
+func setValue(values map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		current = configSection(current, part)
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// applyOverrides 把覆盖按顺序应用到配置值上，后面的覆盖优先
+func applyOverrides(values map[string]interface{}, overrides []configOverride) error {
+	for _, o := range overrides {
+		key, err := lookupWritableConfigKey(o.Key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", o.Origin, err)
+		}
+		value, err := key.Parse(o.Value)
+		if err != nil {
+			return fmt.Errorf("%s 的值无效: %v", o.Origin, err)
+		}
+		setValue(values, key.Key, value)
+	}
+	return nil
+}
+
+// envConfigOverrides 从环境变量中读取 ORBIT_* 配置覆盖
+func envConfigOverrides(lookup func(string) (string, bool)) []configOverride {
+	var overrides []configOverride
+	for _, key := range configKeyRegistry {
+		if key.ReadOnly {
+			continue
+		}
+		if value, ok := lookup(key.EnvName()); ok {
+			overrides = append(overrides, configOverride{
+				Key:    key.Key,
+				Value:  value,
+				Origin: "env " + key.EnvName(),
+			})
+		}
+	}
+	return overrides
+}
+
+// configFlagBinding 把某个命令的参数绑定到配置键
+type configFlagBinding struct {
+	cmd  *cobra.Command
+	flag string
+	key  string
+}
+
+var configFlagBindings []configFlagBinding
+
+// bindConfigFlag 声明命令参数 flag 覆盖配置键 key，只在用户显式传入参数时生效
+func bindConfigFlag(cmd *cobra.Command, flag, key string) {
+	configFlagBindings = append(configFlagBindings, configFlagBinding{cmd: cmd, flag: flag, key: key})
+}
+
+// flagConfigOverrides 返回当前命令中被显式设置的参数对应的配置覆盖
+func flagConfigOverrides(cmd *cobra.Command) []configOverride {
+	var overrides []configOverride
+	for _, binding := range configFlagBindings {
+		if binding.cmd != cmd {
+			continue
+		}
+		flag := cmd.Flags().Lookup(binding.flag)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		overrides = append(overrides, configOverride{
+			Key:    binding.key,
+			Value:  flag.Value.String(),
+			Origin: "flag --" + binding.flag,
+		})
+	}
+	return overrides
+}
+
+// commandConfigOverrides 按优先级返回环境变量和命令行参数的覆盖
+func commandConfigOverrides(cmd *cobra.Command) []configOverride {
+	overrides := envConfigOverrides(os.LookupEnv)
+	return append(overrides, flagConfigOverrides(cmd)...)
+}
+
+// configValueOrigins 计算每个配置键的有效值来源：
+// default -> file -> profile <name> -> env ORBIT_* -> flag --xxx
+func configValueOrigins(defaults, root *UserConfig, profile string, overrides []configOverride) (map[string]string, error) {
+	defaultValues, err := configToValues(defaults)
+	if err != nil {
+		return nil, err
+	}
+	rootValues, err := configToValues(root)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := profileChain(root, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]string)
+	for _, key := range configKeyRegistry {
+		origins[key.Key] = originDefault
+
+		rootValue, _ := lookupValue(rootValues, key.Key)
+		defaultValue, _ := lookupValue(defaultValues, key.Key)
+		if !reflect.DeepEqual(rootValue, defaultValue) {
+			origins[key.Key] = originFile
+		}
+		for _, name := range chain {
+			if _, ok := lookupValue(root.Profiles[name].Values, key.Key); ok {
+				origins[key.Key] = "profile " + name
+			}
+		}
+	}
+	for _, o := range overrides {
+		origins[o.Key] = o.Origin
+	}
+	return origins, nil
+}
+
+// formatConfigValue 把配置值格式化为便于阅读的字符串
+func formatConfigValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
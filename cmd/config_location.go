@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// ConfigFileName 是用户配置文件名
+const ConfigFileName = "info.json"
+
+// PortableMarkerName 放在可执行文件旁边时启用便携模式
+const PortableMarkerName = "orbit.portable"
+
+// PortableDataDirName 是便携模式下存放配置、密钥和日志的目录，位于可执行文件旁边
+const PortableDataDirName = "orbit_data"
+
+// 通过 --config 指定的配置文件路径
+var configFlagPath string
+
+// configLocation 描述配置文件的位置以及它是如何确定的
+type configLocation struct {
+	Path   string
+	Source string
+}
+
+// isPortableMode 判断是否以便携模式运行：设置了 ORBIT_PORTABLE，或可执行文件旁边有 orbit.portable
+func isPortableMode(lookup func(string) (string, bool), exeDir string) bool {
+	if value, ok := lookup("ORBIT_PORTABLE"); ok {
+		enabled, err := parseConfigBool(value)
+		return err == nil && enabled
+	}
+	if exeDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(exeDir, PortableMarkerName))
+	return err == nil
+}
+
+// executableDir 返回可执行文件所在目录，获取失败时返回空字符串
+func executableDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// defaultConfigDir 返回平台默认的配置目录：
+// Windows 为 %APPDATA%\orbit_user（与旧版本一致），
+// Linux 为 $XDG_CONFIG_HOME/orbit（默认 ~/.config/orbit），macOS 为 ~/Library/Application Support/orbit
+func defaultConfigDir() string {
+	name := "orbit"
+	if runtime.GOOS == "windows" {
+		name = "orbit_user"
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		logger.Warnf("无法确定用户配置目录，使用当前目录: %v", err)
+		return filepath.Join(CurrentDir, name)
+	}
+	return filepath.Join(base, name)
+}
+
+// configFilePath 把用户给出的路径规范为配置文件路径，给出的是已存在的目录时使用其中的 info.json
+func configFilePath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, ConfigFileName)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// resolveConfigLocation 按 --config、ORBIT_CONFIG、便携模式、平台默认目录 的顺序确定配置文件位置
+func resolveConfigLocation(flagPath string, lookup func(string) (string, bool), exeDir string) configLocation {
+	if flagPath != "" {
+		return configLocation{Path: configFilePath(flagPath), Source: "flag --config"}
+	}
+	if value, ok := lookup("ORBIT_CONFIG"); ok && value != "" {
+		return configLocation{Path: configFilePath(value), Source: "env ORBIT_CONFIG"}
+	}
+	if isPortableMode(lookup, exeDir) && exeDir != "" {
+		return configLocation{
+			Path:   filepath.Join(exeDir, PortableDataDirName, ConfigFileName),
+			Source: "portable",
+		}
+	}
+	return configLocation{Path: filepath.Join(defaultConfigDir(), ConfigFileName), Source: originDefault}
+}
+
+// currentConfigLocation 返回本次运行使用的配置文件位置
+func currentConfigLocation() configLocation {
+	return resolveConfigLocation(configFlagPath, os.LookupEnv, executableDir())
+}
+
+// LogDir 返回日志根目录：便携模式下位于可执行文件旁边的 orbit_data/logs，否则为当前目录下的 logs。
+// 日志在解析命令行参数之前创建，因此只考虑 ORBIT_PORTABLE 和 orbit.portable 标记文件。
+func LogDir() string {
+	exeDir := executableDir()
+	if isPortableMode(os.LookupEnv, exeDir) && exeDir != "" {
+		return filepath.Join(exeDir, PortableDataDirName, "logs")
+	}
+	return "logs"
+}
+
+// configPathCmd 显示配置文件位置
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Show the location of the configuration file",
+	Long: `Print the configuration file in use and how it was chosen.
+
+The location is resolved in this order:
+  1. --config FILE|DIR
+  2. the ORBIT_CONFIG environment variable
+  3. portable mode: orbit_data/info.json next to the executable, enabled by an
+     orbit.portable file next to the executable or ORBIT_PORTABLE=1
+  4. the platform default: %APPDATA%\orbit_user on Windows,
+     $XDG_CONFIG_HOME/orbit (~/.config/orbit) on Linux and
+     ~/Library/Application Support/orbit on macOS`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		location := currentConfigLocation()
+		logger.Infof("配置文件: %s [%s]", location.Path, location.Source)
+		logger.Infof("密钥目录: %s", filepath.Join(filepath.Dir(location.Path), "keys"))
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPathCmd)
+}
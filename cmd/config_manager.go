@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,10 +15,17 @@ var keysPath string
 // ConfigManager 管理应用程序配置的加载、保存和验证
 type ConfigManager struct {
 	configPath   string
-	userConfig   *UserConfig
+	userConfig   *UserConfig      // info.json 的完整内容，包含所有 profile
+	profile      string           // 通过 --profile 指定的 profile，为空时使用 active_profile
+	overrides    []configOverride // 环境变量和命令行参数的覆盖，只影响有效配置
+	effective    *UserConfig      // 当前 profile 合并继承并应用覆盖后的有效配置
 	mu           sync.RWMutex
 	lastLoadTime time.Time
 	autoSave     bool
+	command      string // 当前命令行，记录在配置修改日志中
+
+	subscribers    map[int]configSubscriber // 配置变化的订阅者，见 Subscribe
+	nextSubscriber int
 }
 
 // NewConfigManager 创建新的配置管理器
@@ -33,6 +41,13 @@ func (cm *ConfigManager) LoadConfig() error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	// 跨进程锁，避免读到其他 orbit 进程正在写入的配置
+	lock, err := lockFile(cm.configPath)
+	if err != nil {
+		return fmt.Errorf("锁定配置文件失败: %w", err)
+	}
+	defer lock.Unlock()
+
 	// 检查配置文件是否存在
 	if _, err := os.Stat(cm.configPath); os.IsNotExist(err) {
 		// 创建默认配置
@@ -45,96 +60,201 @@ func (cm *ConfigManager) LoadConfig() error {
 		return fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
-	// 解析JSON
-	var config UserConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("解析配置文件失败: %w", err)
+	// 解析并迁移配置，损坏时回退到最近一次有效的配置；写入失败等其他错误直接返回
+	config, err := cm.decodeConfig(data)
+	if errors.Is(err, errConfigCorrupt) {
+		data, err = cm.recoverLastGoodConfig(err)
+		if err != nil {
+			return err
+		}
+		if config, err = cm.decodeConfig(data); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
 	}
 
-	// 验证配置
-	if err := cm.validateConfig(&config); err != nil {
-		return fmt.Errorf("配置验证失败: %w", err)
+	// 配置有错误时仍然加载，以便 config validate / config repair 能够处理
+	if errCount, _ := countIssues(validateUserConfig(config)); errCount > 0 {
+		logger.Warnf("配置中有 %d 个错误，运行 orbit config validate 查看详情", errCount)
 	}
 
-	cm.userConfig = &config
+	effective, err := cm.resolveEffective(config)
+	if err != nil {
+		return err
+	}
+
+	cm.userConfig = config
+	cm.effective = effective
 	cm.lastLoadTime = time.Now()
 
-	logger.Infof("配置已从 %s 加载", cm.configPath)
+	logger.Infof("配置已从 %s 加载 (profile: %s)", cm.configPath, selectedProfile(config, cm.profile))
 	return nil
 }
 
 // SaveConfig 保存配置到文件
 func (cm *ConfigManager) SaveConfig() error {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
 	if cm.userConfig == nil {
 		return fmt.Errorf("没有配置可保存")
 	}
 
-	// 更新最后更新时间
-	cm.userConfig.LastUpdate = time.Now().Format("2006-01-02 15:04:05")
-
-	// 确保配置目录存在
-	if err := os.MkdirAll(filepath.Dir(cm.configPath), 0755); err != nil {
-		return fmt.Errorf("创建配置目录失败: %w", err)
-	}
-
-	// 序列化配置
-	data, err := json.MarshalIndent(cm.userConfig, "", "  ")
+	lock, err := lockFile(cm.configPath)
 	if err != nil {
-		return fmt.Errorf("序列化配置失败: %w", err)
+		return fmt.Errorf("锁定配置文件失败: %w", err)
 	}
+	defer lock.Unlock()
 
-	// 写入文件
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败: %w", err)
+	if err := cm.saveConfigLocked(); err != nil {
+		return err
 	}
 
 	logger.Infof("配置已保存到 %s", cm.configPath)
 	return nil
 }
 
-// GetConfig 获取当前配置的副本
+// GetConfig 获取当前 profile 有效配置的副本
 func (cm *ConfigManager) GetConfig() *UserConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	if cm.userConfig == nil {
+	if cm.effective == nil {
 		return nil
 	}
 
 	// 返回配置的深拷贝
+	return cm.deepCopyConfig(cm.effective)
+}
+
+// GetRootConfig 获取 info.json 完整内容（包含所有 profile）的副本
+func (cm *ConfigManager) GetRootConfig() *UserConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.userConfig == nil {
+		return nil
+	}
 	return cm.deepCopyConfig(cm.userConfig)
 }
 
-// UpdateConfig 更新配置并自动保存
+// UpdateConfig 更新当前 profile 的配置并自动保存
 func (cm *ConfigManager) UpdateConfig(updater func(*UserConfig)) error {
+	return cm.updateProfile(func(config *UserConfig, parent *UserConfig) error {
+		updater(config)
+		return nil
+	})
+}
+
+// updateProfile 修改当前 profile 的配置（不含环境变量和参数覆盖）。
+// parent 是当前 profile 继承的配置，对 default profile 来说是内置默认值。
+func (cm *ConfigManager) updateProfile(updater func(config *UserConfig, parent *UserConfig) error) error {
+	return cm.updateRoot(func(root *UserConfig) error {
+		name := selectedProfile(root, cm.profile)
+		effective, err := resolveProfile(root, name)
+		if err != nil {
+			return err
+		}
+
+		parent := cm.getDefaultConfig()
+		if name != DefaultProfileName {
+			if parent, err = resolveProfile(root, root.Profiles[name].parentName()); err != nil {
+				return err
+			}
+		}
+
+		before := cm.deepCopyConfig(effective)
+		if err := updater(effective, parent); err != nil {
+			return err
+		}
+
+		// 验证新配置，只拒绝本次修改引入的错误
+		if issues := newConfigErrors(before, effective); len(issues) > 0 {
+			return fmt.Errorf("配置更新验证失败: %w", issuesError(issues))
+		}
+		return applyProfileUpdate(root, name, effective)
+	})
+}
+
+// UpdateRootConfig 直接修改 info.json 的完整内容（如创建、切换 profile）
+func (cm *ConfigManager) UpdateRootConfig(updater func(*UserConfig) error) error {
+	return cm.updateRoot(updater)
+}
+
+// updateRoot 在持有进程内锁和文件锁的情况下完成 读取-修改-写入
+func (cm *ConfigManager) updateRoot(updater func(*UserConfig) error) error {
+	return cm.commitRoot(updater, nil)
+}
+
+// commitRoot 与 updateRoot 相同，保存后把修改追加到修改日志，reverts 是本次撤销的修改编号
+func (cm *ConfigManager) commitRoot(updater func(*UserConfig) error, reverts []int) error {
+	previous, current, err := cm.applyRootUpdate(updater, reverts)
+	if err != nil {
+		return err
+	}
+
+	// 释放锁之后再通知订阅者，订阅者可以读取配置
+	cm.notifySubscribers(previous, current)
+	return nil
+}
+
+// applyRootUpdate 完成修改并返回修改前后的有效配置
+func (cm *ConfigManager) applyRootUpdate(updater func(*UserConfig) error, reverts []int) (*UserConfig, *UserConfig, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	if cm.userConfig == nil {
-		return fmt.Errorf("配置未加载")
+		return nil, nil, fmt.Errorf("配置未加载")
+	}
+
+	// 持有跨进程锁完成 读取-修改-写入，避免多个 orbit 进程互相覆盖
+	lock, err := lockFile(cm.configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("锁定配置文件失败: %w", err)
+	}
+	defer lock.Unlock()
+
+	// 自动保存时以磁盘上的最新配置为基础，合并其他进程的修改
+	base := cm.userConfig
+	if cm.autoSave {
+		if diskConfig, err := readUserConfigFile(cm.configPath); err == nil &&
+			diskConfig.SchemaVersion == cm.userConfig.SchemaVersion {
+			base = diskConfig
+		}
 	}
 
 	// 创建配置副本进行修改
-	newConfig := cm.deepCopyConfig(cm.userConfig)
-	updater(newConfig)
+	newConfig := cm.deepCopyConfig(base)
+	if err := updater(newConfig); err != nil {
+		return nil, nil, err
+	}
 
-	// 验证新配置
-	if err := cm.validateConfig(newConfig); err != nil {
-		return fmt.Errorf("配置更新验证失败: %w", err)
+	// 验证新配置，只拒绝本次修改引入的错误
+	if issues := newConfigErrors(base, newConfig); len(issues) > 0 {
+		return nil, nil, fmt.Errorf("配置更新验证失败: %w", issuesError(issues))
+	}
+	effective, err := cm.resolveEffective(newConfig)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// 更新配置
+	previous := cm.effective
 	cm.userConfig = newConfig
+	cm.effective = effective
 
 	// 自动保存
 	if cm.autoSave {
-		return cm.saveConfigLocked()
+		if err := cm.saveConfigLocked(); err != nil {
+			return nil, nil, err
+		}
+		if err := cm.appendConfigHistory(base, newConfig, reverts); err != nil {
+			logger.Warnf("记录配置修改历史失败: %v", err)
+		}
 	}
 
-	return nil
+	return previous, effective, nil
 }
 
 // GetVSCodeConfig 获取VSCode配置
@@ -142,10 +262,10 @@ func (cm *ConfigManager) GetVSCodeConfig() *VSCodeConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	if cm.userConfig == nil {
+	if cm.effective == nil {
 		return nil
 	}
-	return &cm.userConfig.VSCode
+	return &cm.effective.VSCode
 }
 
 // GetSoftwareConfig 获取软件配置
@@ -153,10 +273,10 @@ func (cm *ConfigManager) GetSoftwareConfig() *SoftwareConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	if cm.userConfig == nil {
+	if cm.effective == nil {
 		return nil
 	}
-	return &cm.userConfig.Software
+	return &cm.effective.Software
 }
 
 // GetEncryptionConfig 获取加密配置
@@ -164,10 +284,10 @@ func (cm *ConfigManager) GetEncryptionConfig() *EncryptionConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	if cm.userConfig == nil {
+	if cm.effective == nil {
 		return nil
 	}
-	return &cm.userConfig.Encryption
+	return &cm.effective.Encryption
 }
 
 // GetSystemConfig 获取系统配置
@@ -175,10 +295,47 @@ func (cm *ConfigManager) GetSystemConfig() *SystemConfig {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	if cm.userConfig == nil {
+	if cm.effective == nil {
 		return nil
 	}
-	return &cm.userConfig.System
+	return &cm.effective.System
+}
+
+// SetProfile 指定使用的 profile，为空时使用配置文件中的 active_profile
+func (cm *ConfigManager) SetProfile(name string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.profile = name
+}
+
+// SetOverrides 设置环境变量和命令行参数的覆盖，需在 LoadConfig 之前调用
+func (cm *ConfigManager) SetOverrides(overrides []configOverride) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.overrides = overrides
+}
+
+// ConfigOrigins 返回每个配置键的有效值来源
+func (cm *ConfigManager) ConfigOrigins() (map[string]string, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.userConfig == nil {
+		return nil, fmt.Errorf("配置未加载")
+	}
+	name := selectedProfile(cm.userConfig, cm.profile)
+	return configValueOrigins(cm.getDefaultConfig(), cm.userConfig, name, cm.overrides)
+}
+
+// GetProfileName 返回当前使用的 profile 名称
+func (cm *ConfigManager) GetProfileName() string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.userConfig == nil {
+		return DefaultProfileName
+	}
+	return selectedProfile(cm.userConfig, cm.profile)
 }
 
 // UpdateSystemConfig 更新系统配置（如备份计数）
@@ -216,13 +373,8 @@ func (cm *ConfigManager) SetAutoSave(autoSave bool) {
 
 // 内部方法
 
-// createDefaultConfig 创建默认配置
+// createDefaultConfig 创建默认配置，调用方需持有文件锁
 func (cm *ConfigManager) createDefaultConfig() error {
-	// 确保配置目录存在
-	if err := os.MkdirAll(filepath.Dir(cm.configPath), 0755); err != nil {
-		return fmt.Errorf("创建配置目录失败: %w", err)
-	}
-
 	// 创建默认配置
 	defaultConfig := cm.getDefaultConfig()
 
@@ -232,11 +384,17 @@ func (cm *ConfigManager) createDefaultConfig() error {
 		return fmt.Errorf("序列化默认配置失败: %w", err)
 	}
 
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+	if err := cm.writeConfigFile(data); err != nil {
 		return fmt.Errorf("写入默认配置文件失败: %w", err)
 	}
 
+	effective, err := cm.resolveEffective(defaultConfig)
+	if err != nil {
+		return err
+	}
+
 	cm.userConfig = defaultConfig
+	cm.effective = effective
 	cm.lastLoadTime = time.Now()
 
 	logger.Infof("默认配置已创建并保存到 %s", cm.configPath)
@@ -248,6 +406,7 @@ func (cm *ConfigManager) getDefaultConfig() *UserConfig {
 	keysPath = filepath.Join(filepath.Dir(cm.configPath), "keys")
 
 	return &UserConfig{
+		SchemaVersion: CurrentConfigSchemaVersion,
 		System: SystemConfig{
 			LastBackupTime:    "",
 			BackupCount:       0,
@@ -288,29 +447,21 @@ func (cm *ConfigManager) getDefaultConfig() *UserConfig {
 	}
 }
 
-// validateConfig 验证配置的有效性
-func (cm *ConfigManager) validateConfig(config *UserConfig) error {
-	// 验证系统配置
-	if config.System.DefaultBackupPath == "" {
-		return fmt.Errorf("默认备份路径不能为空")
+// resolveEffective 按 默认值 -> 配置文件 -> profile -> 环境变量 -> 命令行参数 的顺序计算有效配置
+func (cm *ConfigManager) resolveEffective(root *UserConfig) (*UserConfig, error) {
+	name := selectedProfile(root, cm.profile)
+	values, err := resolveProfileValues(root, name)
+	if err != nil {
+		return nil, fmt.Errorf("加载 profile 失败: %w", err)
 	}
-
-	// 验证VSCode配置
-	if len(config.VSCode.ConfigDirs) == 0 {
-		return fmt.Errorf("VSCode配置目录不能为空")
+	if err := applyOverrides(values, cm.overrides); err != nil {
+		return nil, err
 	}
-
-	// 验证加密配置
-	if config.Encryption.Enabled {
-		if config.Encryption.PublicKeyPath == "" {
-			return fmt.Errorf("启用加密时公钥路径不能为空")
-		}
-		if config.Encryption.PrivateKeyPath == "" {
-			return fmt.Errorf("启用加密时私钥路径不能为空")
-		}
+	effective, err := valuesToConfig(root, values)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return effective, nil
 }
 
 // deepCopyConfig 创建配置的深拷贝
@@ -331,7 +482,7 @@ func (cm *ConfigManager) deepCopyConfig(config *UserConfig) *UserConfig {
 	return &copyConfig
 }
 
-// saveConfigLocked 在已持有锁的情况下保存配置
+// saveConfigLocked 在已持有锁（包括文件锁）的情况下保存配置
 func (cm *ConfigManager) saveConfigLocked() error {
 	if cm.userConfig == nil {
 		return fmt.Errorf("没有配置可保存")
@@ -346,21 +497,110 @@ func (cm *ConfigManager) saveConfigLocked() error {
 		return fmt.Errorf("序列化配置失败: %w", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+	return cm.writeConfigFile(data)
+}
+
+// writeConfigFile 原子写入配置文件，并同步更新最近一次有效配置的备份
+func (cm *ConfigManager) writeConfigFile(data []byte) error {
+	if err := writeFileAtomic(cm.configPath, data, 0644); err != nil {
 		return fmt.Errorf("写入配置文件失败: %w", err)
 	}
 
+	if err := writeFileAtomic(lastGoodConfigPath(cm.configPath), data, 0644); err != nil {
+		logger.Warnf("更新配置备份失败: %v", err)
+	}
 	return nil
 }
 
+// recoverLastGoodConfig 在配置文件损坏时读取最近一次有效的配置并写回
+func (cm *ConfigManager) recoverLastGoodConfig(cause error) ([]byte, error) {
+	backupPath := lastGoodConfigPath(cm.configPath)
+	logger.Warnf("配置文件 %s 已损坏 (%v)，尝试使用备份 %s", cm.configPath, cause, backupPath)
+
+	data, err := os.ReadFile(backupPath)
+	if err != nil || !json.Valid(data) {
+		return nil, fmt.Errorf("解析配置文件失败: %w", cause)
+	}
+
+	// 保留损坏的文件以便排查，再用备份覆盖
+	os.Rename(cm.configPath, cm.configPath+".corrupt")
+	if err := writeFileAtomic(cm.configPath, data, 0644); err != nil {
+		logger.Warnf("恢复配置文件失败: %v", err)
+	} else {
+		logger.Infof("已从备份恢复配置文件，损坏的文件保存为 %s", cm.configPath+".corrupt")
+	}
+
+	return data, nil
+}
+
+// errConfigCorrupt 表示配置文件无法解析或迁移，LoadConfig 只在这种情况下回退到备份
+var errConfigCorrupt = errors.New("配置文件已损坏")
+
+// decodeConfig 解析配置数据，旧版本配置会先迁移到当前版本并写回，
+// 迁移前的原始文件保存为 info.json.v<版本>.bak。无法解析或迁移时返回包装了
+// errConfigCorrupt 的错误，写入备份或迁移结果失败时原样返回。
+func (cm *ConfigManager) decodeConfig(data []byte) (*UserConfig, error) {
+	migrated, fromVersion, err := migrateConfigData(data, forceConfigLoad)
+	if errors.Is(err, errNewerConfigSchema) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfigCorrupt, err)
+	}
+
+	var config UserConfig
+	if err := json.Unmarshal(migrated, &config); err != nil {
+		return nil, fmt.Errorf("%w: 解析配置文件失败: %w", errConfigCorrupt, err)
+	}
+
+	if fromVersion >= CurrentConfigSchemaVersion {
+		return &config, nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", cm.configPath, fromVersion)
+	if err := writeFileAtomic(backupPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("备份迁移前的配置失败: %w", err)
+	}
+
+	out, err := json.MarshalIndent(&config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %w", err)
+	}
+	if err := cm.writeConfigFile(out); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("配置已从版本 %d 迁移到 %d，迁移前的文件备份为 %s", fromVersion, CurrentConfigSchemaVersion, backupPath)
+	return &config, nil
+}
+
+// readUserConfigFile 读取并解析配置文件
+func readUserConfigFile(path string) (*UserConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var config UserConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	return &config, nil
+}
+
 // 全局配置管理器实例
 var globalConfigManager *ConfigManager
 
 // InitGlobalConfigManager 初始化全局配置管理器
 func InitGlobalConfigManager() error {
-	configPath := filepath.Join(os.Getenv("APPDATA"), "orbit_user", "info.json")
-	globalConfigManager = NewConfigManager(configPath)
+	location := currentConfigLocation()
+	if location.Source != originDefault {
+		logger.Infof("使用配置文件 %s [%s]", location.Path, location.Source)
+	}
+	globalConfigManager = NewConfigManager(location.Path)
+	globalConfigManager.SetProfile(profileName)
+	globalConfigManager.SetOverrides(configOverrides)
 	return globalConfigManager.LoadConfig()
 }
 
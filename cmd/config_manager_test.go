@@ -0,0 +1,798 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newTestConfigManager(t *testing.T) *ConfigManager {
+	t.Helper()
+	cm := NewConfigManager(filepath.Join(t.TempDir(), "orbit_user", "info.json"))
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func TestConfigConcurrentUpdates(t *testing.T) {
+	first := newTestConfigManager(t)
+
+	// 第二个管理器模拟另一个 orbit 进程
+	second := NewConfigManager(first.configPath)
+	if err := second.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, cm := range []*ConfigManager{first, second} {
+		wg.Add(1)
+		go func(cm *ConfigManager) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := cm.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount++ }); err != nil {
+					t.Error(err)
+				}
+			}
+		}(cm)
+	}
+	wg.Wait()
+
+	config, err := readUserConfigFile(first.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.System.BackupCount != 50 {
+		t.Fatalf("got BackupCount %d, want 50", config.System.BackupCount)
+	}
+
+	// 原子写入不应留下临时文件
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(first.configPath), "info.json.tmp-*"))
+	if len(matches) != 0 {
+		t.Fatalf("leftover temp files: %v", matches)
+	}
+}
+
+func TestConfigRecoverFromLastGood(t *testing.T) {
+	cm := newTestConfigManager(t)
+	if err := cm.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount = 7 }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟写入到一半崩溃留下的截断文件
+	data, _ := os.ReadFile(cm.configPath)
+	os.WriteFile(cm.configPath, data[:len(data)/2], 0644)
+
+	reloaded := NewConfigManager(cm.configPath)
+	if err := reloaded.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.GetSystemConfig().BackupCount; got != 7 {
+		t.Fatalf("got BackupCount %d, want 7", got)
+	}
+	if _, err := readUserConfigFile(cm.configPath); err != nil {
+		t.Fatalf("config file was not restored: %v", err)
+	}
+	if _, err := os.Stat(cm.configPath + ".corrupt"); err != nil {
+		t.Fatalf("corrupt file was not kept: %v", err)
+	}
+}
+
+func TestConfigMigrateFromUnversioned(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "info.json")
+	legacy := `{
+  "system": {"default_backup_path": "D:\\backups", "backup_count": 3},
+  "vscode": {"config_dirs": [{"Name": "APPDATA", "Path": "C:\\Code", "OriginalPath": "C:\\Code"}]},
+  "encryption": {"enabled": false}
+}`
+	os.WriteFile(configPath, []byte(legacy), 0644)
+
+	cm := NewConfigManager(configPath)
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	config := cm.GetConfig()
+	if config.SchemaVersion != CurrentConfigSchemaVersion {
+		t.Fatalf("got schema version %d", config.SchemaVersion)
+	}
+	if !config.VSCode.BackupSetting || config.Encryption.DefaultAlgorithm != "RSA-2048" || config.System.BackupCount != 3 {
+		t.Fatalf("migration did not fill defaults: %+v", config)
+	}
+
+	backup, err := os.ReadFile(configPath + ".v0.bak")
+	if err != nil || string(backup) != legacy {
+		t.Fatalf("pre-migration backup missing or changed: %v", err)
+	}
+	onDisk, _ := readUserConfigFile(configPath)
+	if onDisk.SchemaVersion != CurrentConfigSchemaVersion {
+		t.Fatal("migrated config was not written back")
+	}
+}
+
+func TestConfigMigrationWriteFailure(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "info.json")
+	legacy := `{"system": {"default_backup_path": "."}}`
+	os.WriteFile(configPath, []byte(legacy), 0644)
+	// 备份路径是目录，写入迁移前的备份会失败
+	if err := os.Mkdir(configPath+".v0.bak", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := NewConfigManager(configPath).LoadConfig()
+	if err == nil || errors.Is(err, errConfigCorrupt) {
+		t.Fatalf("write failure should be returned as is, got %v", err)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != legacy {
+		t.Fatal("valid config should be left in place")
+	}
+	if _, err := os.Stat(configPath + ".corrupt"); !os.IsNotExist(err) {
+		t.Fatalf("valid config should not be moved aside: %v", err)
+	}
+}
+
+func TestConfigRefuseNewerSchema(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "info.json")
+	newer := `{"schema_version": 999, "system": {"default_backup_path": "."},
+  "vscode": {"config_dirs": [{"Name": "USER", "Path": ".", "OriginalPath": "."}]}}`
+	os.WriteFile(configPath, []byte(newer), 0644)
+
+	if err := NewConfigManager(configPath).LoadConfig(); !errors.Is(err, errNewerConfigSchema) {
+		t.Fatalf("got %v, want errNewerConfigSchema", err)
+	}
+
+	forceConfigLoad = true
+	defer func() { forceConfigLoad = false }()
+	if err := NewConfigManager(configPath).LoadConfig(); err != nil {
+		t.Fatalf("forced load failed: %v", err)
+	}
+}
+
+func TestConfigProfileInheritance(t *testing.T) {
+	cm := newTestConfigManager(t)
+	if err := cm.UpdateConfig(func(c *UserConfig) {
+		c.System.DefaultBackupPath = "base-backups"
+		c.Software.ExcludedPatterns = []string{"Base*"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := createProfile(cm, "work", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := createProfile(cm, "work", ""); err == nil {
+		t.Fatal("creating a duplicate profile should fail")
+	}
+
+	work := NewConfigManager(cm.configPath)
+	work.SetProfile("work")
+	if err := work.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := work.UpdateConfig(func(c *UserConfig) {
+		c.System.DefaultBackupPath = "work-backups"
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// profile 只保存与父 profile 不同的值
+	root := work.GetRootConfig()
+	values := root.Profiles["work"].Values
+	if countValues(values) != 1 {
+		t.Fatalf("work profile stores %d values, want 1: %v", countValues(values), values)
+	}
+	if root.System.DefaultBackupPath != "base-backups" {
+		t.Fatalf("default profile was modified: %s", root.System.DefaultBackupPath)
+	}
+
+	// 未覆盖的值跟随 default 变化
+	if err := cm.UpdateConfig(func(c *UserConfig) {
+		c.Software.ExcludedPatterns = []string{"Shared*"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := work.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	config := work.GetConfig()
+	if config.System.DefaultBackupPath != "work-backups" {
+		t.Fatalf("got backup path %s, want work-backups", config.System.DefaultBackupPath)
+	}
+	if len(config.Software.ExcludedPatterns) != 1 || config.Software.ExcludedPatterns[0] != "Shared*" {
+		t.Fatalf("inherited patterns not applied: %v", config.Software.ExcludedPatterns)
+	}
+}
+
+func TestConfigProfileUseAndDelete(t *testing.T) {
+	cm := newTestConfigManager(t)
+	if err := createProfile(cm, "work", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := createProfile(cm, "laptop", "work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := useProfile(cm, "laptop"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.GetProfileName(); got != "laptop" {
+		t.Fatalf("got profile %s, want laptop", got)
+	}
+
+	// --profile 优先于 active_profile
+	override := NewConfigManager(cm.configPath)
+	override.SetProfile(DefaultProfileName)
+	if err := override.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := override.GetProfileName(); got != DefaultProfileName {
+		t.Fatalf("got profile %s, want %s", got, DefaultProfileName)
+	}
+
+	missing := NewConfigManager(cm.configPath)
+	missing.SetProfile("nope")
+	if err := missing.LoadConfig(); err == nil {
+		t.Fatal("loading a missing profile should fail")
+	}
+
+	if err := deleteProfile(cm, "work"); err == nil {
+		t.Fatal("deleting an inherited profile should fail")
+	}
+	if err := deleteProfile(cm, DefaultProfileName); err == nil {
+		t.Fatal("deleting the default profile should fail")
+	}
+	if err := deleteProfile(cm, "laptop"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.GetProfileName(); got != DefaultProfileName {
+		t.Fatalf("got profile %s after delete, want %s", got, DefaultProfileName)
+	}
+}
+
+func TestConfigLayeredOverrides(t *testing.T) {
+	cm := newTestConfigManager(t)
+	if err := cm.UpdateConfig(func(c *UserConfig) {
+		c.System.DefaultBackupPath = "file-backups"
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"ORBIT_ENCRYPTION_ENABLED":         "false",
+		"ORBIT_SOFTWARE_EXCLUDED_PATTERNS": "Foo*, Bar*",
+		"ORBIT_ENCRYPTION_PUBLIC_KEY_PATH": "env.pem",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cmd := &cobra.Command{Use: "test"}
+	var key string
+	cmd.Flags().StringVar(&key, "public-key", "", "")
+	bindConfigFlag(cmd, "public-key", "encryption.public_key_path")
+	if err := cmd.Flags().Parse([]string{"--public-key", "flag.pem"}); err != nil {
+		t.Fatal(err)
+	}
+
+	layered := NewConfigManager(cm.configPath)
+	layered.SetOverrides(append(envConfigOverrides(lookup), flagConfigOverrides(cmd)...))
+	if err := layered.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	config := layered.GetConfig()
+	if config.Encryption.Enabled {
+		t.Fatal("env override for encryption.enabled not applied")
+	}
+	if config.Encryption.PublicKeyPath != "flag.pem" {
+		t.Fatalf("got public key %s, want flag.pem", config.Encryption.PublicKeyPath)
+	}
+	if len(config.Software.ExcludedPatterns) != 2 || config.Software.ExcludedPatterns[1] != "Bar*" {
+		t.Fatalf("got patterns %v", config.Software.ExcludedPatterns)
+	}
+
+	origins, err := layered.ConfigOrigins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"system.default_backup_path":   originFile,
+		"encryption.enabled":           "env ORBIT_ENCRYPTION_ENABLED",
+		"encryption.public_key_path":   "flag --public-key",
+		"encryption.default_algorithm": originDefault,
+	}
+	for key, origin := range want {
+		if origins[key] != origin {
+			t.Errorf("origin of %s = %q, want %q", key, origins[key], origin)
+		}
+	}
+
+	// 覆盖不会写入配置文件
+	if err := layered.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount++ }); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := readUserConfigFile(cm.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Encryption.Enabled || saved.Encryption.PublicKeyPath == "flag.pem" {
+		t.Fatalf("overrides leaked into the config file: %+v", saved.Encryption)
+	}
+
+	bad := NewConfigManager(cm.configPath)
+	bad.SetOverrides([]configOverride{{Key: "encryption.enabled", Value: "maybe", Origin: "env ORBIT_ENCRYPTION_ENABLED"}})
+	if err := bad.LoadConfig(); err == nil {
+		t.Fatal("invalid override should fail to load")
+	}
+}
+
+func TestConfigKeyRegistry(t *testing.T) {
+	key, err := lookupConfigKey("public-key-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Key != "encryption.public_key_path" || key.EnvName() != "ORBIT_ENCRYPTION_PUBLIC_KEY_PATH" {
+		t.Fatalf("unexpected key %+v", key)
+	}
+	if _, err := lookupConfigKey("no.such.key"); err == nil {
+		t.Fatal("unknown key should fail")
+	}
+	if _, err := lookupWritableConfigKey("system.backup_count"); err == nil {
+		t.Fatal("readonly key should not be writable")
+	}
+
+	enabled, _ := lookupConfigKey("encryption.enabled")
+	for _, raw := range []string{"maybe", "", "tru"} {
+		if _, err := enabled.Parse(raw); err == nil {
+			t.Errorf("Parse(%q) should fail", raw)
+		}
+	}
+	if v, err := enabled.Parse("off"); err != nil || v != false {
+		t.Fatalf("Parse(off) = %v, %v", v, err)
+	}
+}
+
+func TestConfigSetUnsetAndLists(t *testing.T) {
+	cm := newTestConfigManager(t)
+
+	if _, err := setConfigValue(cm, "include-store-apps", "yes"); err != nil {
+		t.Fatal(err)
+	}
+	if !cm.GetSoftwareConfig().IncludeStoreApps {
+		t.Fatal("include_store_apps not set")
+	}
+	if _, err := setConfigValue(cm, "software.include_store_apps", "perhaps"); err == nil {
+		t.Fatal("invalid boolean should fail")
+	}
+	if _, err := setConfigValue(cm, "bogus", "1"); err == nil {
+		t.Fatal("unknown key should fail")
+	}
+
+	if _, err := setConfigValue(cm, "software.excluded_patterns", "A*, B*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, n, err := updateConfigList(cm, "software.excluded_patterns", []string{"B*", "C*"}, addListItems); err != nil || n != 1 {
+		t.Fatalf("add: n=%d err=%v", n, err)
+	}
+	if _, n, err := updateConfigList(cm, "software.excluded_patterns", []string{"A*"}, removeListItems); err != nil || n != 1 {
+		t.Fatalf("remove: n=%d err=%v", n, err)
+	}
+	if got := cm.GetSoftwareConfig().ExcludedPatterns; len(got) != 2 || got[0] != "B*" || got[1] != "C*" {
+		t.Fatalf("got patterns %v", got)
+	}
+	if _, _, err := updateConfigList(cm, "encryption.enabled", []string{"x"}, addListItems); err == nil {
+		t.Fatal("adding to a non-list key should fail")
+	}
+
+	// default profile 中 unset 恢复内置默认值
+	if _, err := unsetConfigValue(cm, "include-store-apps"); err != nil {
+		t.Fatal(err)
+	}
+	if cm.GetSoftwareConfig().IncludeStoreApps {
+		t.Fatal("unset did not restore the default")
+	}
+
+	// 其他 profile 中 unset 删除覆盖，重新继承父 profile 的值
+	if err := createProfile(cm, "work", ""); err != nil {
+		t.Fatal(err)
+	}
+	cm.SetProfile("work")
+	if _, err := setConfigValue(cm, "backup-path", "work-backups"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unsetConfigValue(cm, "backup-path"); err != nil {
+		t.Fatal(err)
+	}
+	if values := cm.GetRootConfig().Profiles["work"].Values; len(values) != 0 {
+		t.Fatalf("work profile still has overrides: %v", values)
+	}
+}
+
+func TestConfigExportImportPortable(t *testing.T) {
+	source := newTestConfigManager(t)
+	if err := source.UpdateConfig(func(c *UserConfig) {
+		c.System.BackupCount = 9
+		c.Software.ExcludedPatterns = []string{"Portable*"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := createProfile(source, "work", ""); err != nil {
+		t.Fatal(err)
+	}
+	source.SetProfile("work")
+	workKey := filepath.Join(filepath.Dir(source.configPath), "keys", "work.pem")
+	if _, err := setConfigValue(source, "public-key-path", workKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// work 下 save 过一次，旧版本还会把备份计数写进 profile
+	if err := source.UpdateSystemConfig(func(c *SystemConfig) {
+		c.BackupCount++
+		c.LastBackupTime = "2024-05-01 12:00:00"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.UpdateRootConfig(func(root *UserConfig) error {
+		setValue(root.Profiles["work"].Values, "system.backup_count", 10)
+		setValue(root.Profiles["work"].Values, "system.last_backup_time", "2024-05-01 12:00:00")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := exportConfigBundle(source, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported struct {
+		Config UserConfig `json:"config"`
+	}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if values := exported.Config.Profiles["work"].Values; countValues(values) != 1 {
+		t.Fatalf("export should drop the backup statistics of the work profile: %v", values)
+	}
+	if exported.Config.System.BackupCount != 0 || exported.Config.System.LastBackupTime != "" {
+		t.Fatalf("export should reset the backup statistics: %+v", exported.Config.System)
+	}
+	if bytes.Contains(data, []byte(filepath.ToSlash(filepath.Dir(source.configPath)))) ||
+		bytes.Contains(data, []byte(filepath.Dir(source.configPath))) {
+		t.Fatalf("export still contains machine-specific paths:\n%s", data)
+	}
+	if !bytes.Contains(data, []byte("${ORBIT_HOME}/keys/public.pem")) {
+		t.Fatalf("export is missing the portable key path:\n%s", data)
+	}
+
+	target := newTestConfigManager(t)
+	if err := target.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount = 2 }); err != nil {
+		t.Fatal(err)
+	}
+	config, err := importConfigBundle(target, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targetKeys := filepath.Join(filepath.Dir(target.configPath), "keys")
+	if config.Encryption.PublicKeyPath != filepath.Join(targetKeys, "public.pem") {
+		t.Fatalf("got public key %s", config.Encryption.PublicKeyPath)
+	}
+	if config.System.BackupCount != 2 {
+		t.Fatalf("local backup count was overwritten: %d", config.System.BackupCount)
+	}
+	if got := config.Software.ExcludedPatterns; len(got) != 1 || got[0] != "Portable*" {
+		t.Fatalf("got patterns %v", got)
+	}
+
+	target.SetProfile("work")
+	if _, err := setConfigValue(target, "backup-setting", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if got := target.GetEncryptionConfig().PublicKeyPath; got != filepath.Join(targetKeys, "work.pem") {
+		t.Fatalf("got work profile key %s", got)
+	}
+	if _, err := os.Stat(target.configPath + ".pre-import.bak"); err != nil {
+		t.Fatalf("previous config was not kept: %v", err)
+	}
+
+	if _, err := importConfigBundle(target, []byte(`{"format":"something-else"}`)); err == nil {
+		t.Fatal("importing a foreign file should fail")
+	}
+}
+
+func TestResolveConfigLocation(t *testing.T) {
+	exeDir := t.TempDir()
+	env := map[string]string{}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	if got := resolveConfigLocation("", lookup, exeDir); got.Source != originDefault {
+		t.Fatalf("got %+v, want the platform default", got)
+	}
+
+	// 可执行文件旁边的 orbit.portable 启用便携模式
+	if err := os.WriteFile(filepath.Join(exeDir, PortableMarkerName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(exeDir, PortableDataDirName, ConfigFileName)
+	if got := resolveConfigLocation("", lookup, exeDir); got.Path != want || got.Source != "portable" {
+		t.Fatalf("got %+v, want %s", got, want)
+	}
+	env["ORBIT_PORTABLE"] = "0"
+	if got := resolveConfigLocation("", lookup, exeDir); got.Source != originDefault {
+		t.Fatalf("ORBIT_PORTABLE=0 should disable portable mode, got %+v", got)
+	}
+
+	// ORBIT_CONFIG 指向目录时使用其中的 info.json
+	envDir := t.TempDir()
+	env["ORBIT_CONFIG"] = envDir
+	if got := resolveConfigLocation("", lookup, exeDir); got.Path != filepath.Join(envDir, ConfigFileName) {
+		t.Fatalf("got %+v", got)
+	}
+
+	// --config 优先级最高
+	flagPath := filepath.Join(t.TempDir(), "custom.json")
+	if got := resolveConfigLocation(flagPath, lookup, exeDir); got.Path != flagPath || got.Source != "flag --config" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestConfigValidateAndRepairByCode(t *testing.T) {
+	cm := newTestConfigManager(t)
+
+	// 更新不能引入新的错误
+	err := cm.UpdateConfig(func(c *UserConfig) {
+		c.Encryption.Enabled = true
+		c.Encryption.PublicKeyPath = ""
+	})
+	if err == nil {
+		t.Fatal("update introducing an error should fail")
+	}
+
+	// 已经有错误的配置仍然可以加载，也可以做无关的修改
+	root := cm.GetRootConfig()
+	root.System.DefaultBackupPath = ""
+	root.Software.ExcludedPatterns = []string{"A", "A"}
+	data, _ := json.Marshal(root)
+	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.LoadConfig(); err != nil {
+		t.Fatalf("config with errors should still load: %v", err)
+	}
+	if err := cm.UpdateSystemConfig(func(c *SystemConfig) { c.BackupCount++ }); err != nil {
+		t.Fatalf("unrelated update should succeed: %v", err)
+	}
+
+	issues := validateUserConfig(cm.GetConfig())
+	found := map[string]ConfigIssue{}
+	for _, issue := range issues {
+		found[issue.Code] = issue
+	}
+	if issue := found[IssueBackupPathEmpty]; issue.Severity != SeverityError || issue.Field != "system.default_backup_path" || issue.Fix == "" {
+		t.Fatalf("unexpected issue %+v", issue)
+	}
+	if found[IssueDuplicateListItems].Severity != SeverityWarning {
+		t.Fatalf("missing duplicate warning in %+v", issues)
+	}
+
+	fixed, remaining, err := repairConfiguration(cm, []string{IssueBackupPathEmpty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 1 || fixed[0].Code != IssueBackupPathEmpty {
+		t.Fatalf("fixed %+v", fixed)
+	}
+	if cm.GetSystemConfig().DefaultBackupPath != CurrentDir {
+		t.Fatalf("backup path not repaired: %q", cm.GetSystemConfig().DefaultBackupPath)
+	}
+	hasDuplicate := false
+	for _, issue := range remaining {
+		hasDuplicate = hasDuplicate || issue.Code == IssueDuplicateListItems
+	}
+	if !hasDuplicate {
+		t.Fatal("issues outside --code should be left alone")
+	}
+
+	if _, _, err := repairConfiguration(cm, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.GetSoftwareConfig().ExcludedPatterns; len(got) != 1 {
+		t.Fatalf("duplicates not removed: %v", got)
+	}
+}
+
+func TestConfigHistoryAndUndo(t *testing.T) {
+	cm := newTestConfigManager(t)
+	cm.SetCommand("orbit config set")
+
+	if _, err := setConfigValue(cm, "software.auto_update_list", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setConfigValue(cm, "vscode.excluded_extensions", "a.b,c.d"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readConfigHistory(cm.historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Fatalf("unexpected history %+v", entries)
+	}
+	change := entries[0].Changes[0]
+	if entries[0].Command != "orbit config set" || change.Key != "software.auto_update_list" ||
+		change.Old != true || change.New != false {
+		t.Fatalf("unexpected entry %+v", entries[0])
+	}
+
+	// 撤销最近一次修改，再撤销一次时跳过已撤销的修改
+	if _, err := undoConfigChanges(cm, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.GetVSCodeConfig().ExcludedExtensions; len(got) != 0 {
+		t.Fatalf("extensions not reverted: %v", got)
+	}
+	undone, err := undoConfigChanges(cm, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone[0].ID != 1 || !cm.GetSoftwareConfig().AutoUpdateList {
+		t.Fatalf("second undo should revert #1, got %+v", undone)
+	}
+	if _, err := undoConfigChanges(cm, 1); err == nil {
+		t.Fatal("nothing left to undo")
+	}
+
+	entries, _ = readConfigHistory(cm.historyPath())
+	if len(entries) != 4 || len(entries[3].Reverts) != 1 || entries[3].Reverts[0] != 1 {
+		t.Fatalf("undo not journaled: %+v", entries)
+	}
+
+	// save 更新的备份计数不记录，undo 撤销的是之前的 set
+	if _, err := setConfigValue(cm, "software.auto_update_list", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.UpdateSystemConfig(func(systemConfig *SystemConfig) {
+		systemConfig.BackupCount++
+		systemConfig.LastBackupTime = "2024-05-01 12:00:00"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ = readConfigHistory(cm.historyPath()); len(entries) != 5 {
+		t.Fatalf("read-only changes should not be journaled: %+v", entries)
+	}
+	if _, err := undoConfigChanges(cm, 1); err != nil {
+		t.Fatal(err)
+	}
+	if system := cm.GetSystemConfig(); !cm.GetSoftwareConfig().AutoUpdateList || system.BackupCount != 1 {
+		t.Fatalf("undo should revert the set and keep the backup count: %+v", system)
+	}
+
+	// 旧版本记录的只读变化被跳过
+	legacy := []configHistoryEntry{
+		{ID: 1, Changes: []configValueChange{{Key: "software.auto_update_list", Old: true, New: false}}},
+		{ID: 2, Changes: []configValueChange{{Key: "system.backup_count", Old: 1.0, New: 2.0}}},
+	}
+	if got := undoableEntries(legacy); len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("entries with only read-only changes should be skipped: %+v", got)
+	}
+}
+
+func TestConfigProfileHistoryAndUndo(t *testing.T) {
+	cm := newTestConfigManager(t)
+	if err := createProfile(cm, "work", ""); err != nil {
+		t.Fatal(err)
+	}
+	work := NewConfigManager(cm.configPath)
+	work.SetProfile("work")
+	if err := work.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := setConfigValue(work, "software.auto_update_list", "false"); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := readConfigHistory(work.historyPath())
+
+	// 在 work 下 save 两次，备份计数写入顶层配置，不进入 profile 和修改日志
+	for i := 0; i < 2; i++ {
+		if err := work.UpdateSystemConfig(func(systemConfig *SystemConfig) {
+			systemConfig.BackupCount++
+			systemConfig.LastBackupTime = "2024-05-01 12:00:00"
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ := readConfigHistory(work.historyPath()); len(entries) != len(before) {
+		t.Fatalf("read-only changes should not be journaled: %+v", entries[len(before):])
+	}
+	root := work.GetRootConfig()
+	if _, ok := lookupValue(root.Profiles["work"].Values, "system.backup_count"); ok {
+		t.Fatalf("profile stores the backup count: %v", root.Profiles["work"].Values)
+	}
+	if root.System.BackupCount != 2 || work.GetSystemConfig().BackupCount != 2 {
+		t.Fatalf("backup count not kept at the top level: %+v", root.System)
+	}
+
+	if _, err := undoConfigChanges(work, 1); err != nil {
+		t.Fatal(err)
+	}
+	if system := work.GetSystemConfig(); !work.GetSoftwareConfig().AutoUpdateList || system.BackupCount != 2 {
+		t.Fatalf("undo should revert the set and keep the backup count: %+v", system)
+	}
+
+	// 旧版本写入 profile 的只读变化同样跳过
+	legacy := []configHistoryEntry{
+		{ID: 1, Changes: []configValueChange{{Key: "profiles.work.values.system.backup_count", Old: 2.0, New: 3.0}}},
+	}
+	if got := undoableEntries(legacy); len(got) != 0 {
+		t.Fatalf("profile read-only changes should be skipped: %+v", got)
+	}
+}
+
+func TestConfigSubscribeAndWatch(t *testing.T) {
+	cm := newTestConfigManager(t)
+
+	events := make(chan ConfigChangeEvent, 10)
+	unsubscribe := cm.Subscribe(func(event ConfigChangeEvent) { events <- event }, "software")
+	defer unsubscribe()
+
+	// 本进程的修改：只通知订阅的前缀
+	if err := cm.UpdateConfig(func(c *UserConfig) { c.VSCode.ExcludedExtensions = []string{"a.b"} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.UpdateConfig(func(c *UserConfig) { c.Software.AutoUpdateList = false }); err != nil {
+		t.Fatal(err)
+	}
+	event := <-events
+	if len(event.Keys) != 1 || event.Keys[0] != "software.auto_update_list" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cm.Watch(ctx, 10*time.Millisecond, 30*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	writeRoot := func(update func(*UserConfig)) {
+		root := cm.GetRootConfig()
+		update(root)
+		data, _ := json.Marshal(root)
+		if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 外部编辑引入错误时不生效
+	writeRoot(func(c *UserConfig) {
+		c.Software.IncludeStoreApps = true
+		c.System.DefaultBackupPath = ""
+	})
+	time.Sleep(150 * time.Millisecond)
+	if cm.GetSoftwareConfig().IncludeStoreApps {
+		t.Fatal("invalid edit should not be applied")
+	}
+
+	writeRoot(func(c *UserConfig) { c.Software.IncludeStoreApps = true })
+	select {
+	case event := <-events:
+		if len(event.Keys) != 1 || event.Keys[0] != "software.include_store_apps" || !event.Config.Software.IncludeStoreApps {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("external edit not reloaded")
+	}
+	if !cm.GetSoftwareConfig().IncludeStoreApps {
+		t.Fatal("reloaded config not in effect")
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// CurrentConfigSchemaVersion 是当前版本 Orbit 写入的配置结构版本，
+// 修改 UserConfig 的字段含义或名称时递增，并在 configMigrations 中注册迁移函数
+const CurrentConfigSchemaVersion = 2
+
+// 是否强制加载由更新版本 Orbit 写入的配置
+var forceConfigLoad bool
+
+var errNewerConfigSchema = errors.New("配置文件由更新版本的 Orbit 写入")
+
+// configMigration 把配置从 From 版本升级到 From+1 版本
+type configMigration struct {
+	From        int
+	Description string
+	Migrate     func(raw map[string]interface{}) error
+}
+
+// configMigrations 按 From 升序注册的迁移函数
+var configMigrations = []configMigration{
+	{
+		From:        0,
+		Description: "添加 schema_version，补全后续版本新增字段的默认值",
+		Migrate: func(raw map[string]interface{}) error {
+			vscode := configSection(raw, "vscode")
+			setConfigDefault(vscode, "backup_setting", true)
+			setConfigDefault(vscode, "excluded_extensions", []interface{}{})
+
+			software := configSection(raw, "software")
+			setConfigDefault(software, "auto_update_list", true)
+			setConfigDefault(software, "excluded_patterns", []interface{}{})
+
+			encryption := configSection(raw, "encryption")
+			setConfigDefault(encryption, "default_algorithm", "RSA-2048")
+
+			system := configSection(raw, "system")
+			setConfigDefault(system, "restore_count", 0)
+			return nil
+		},
+	},
+	{
+		From:        1,
+		Description: "引入命名 profile，原有配置成为 default profile",
+		Migrate: func(raw map[string]interface{}) error {
+			setConfigDefault(raw, "active_profile", DefaultProfileName)
+			return nil
+		},
+	},
+}
+
+// configSection 返回 raw 中名为 name 的对象，不存在时创建
+func configSection(raw map[string]interface{}, name string) map[string]interface{} {
+	if section, ok := raw[name].(map[string]interface{}); ok {
+		return section
+	}
+	section := make(map[string]interface{})
+	raw[name] = section
+	return section
+}
+
+// setConfigDefault 只在字段缺失或为 null 时设置默认值
+func setConfigDefault(section map[string]interface{}, key string, value interface{}) {
+	if v, ok := section[key]; !ok || v == nil {
+		section[key] = value
+	}
+}
+
+// configSchemaVersion 读取原始配置中的 schema_version，缺失视为 0
+func configSchemaVersion(raw map[string]interface{}) int {
+	switch v := raw["schema_version"].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// migrateConfigData 依次运行迁移函数，把配置数据升级到当前版本。
+// 返回迁移后的数据和原始版本；配置版本比当前程序新时，除非 force 否则返回错误。
+func migrateConfigData(data []byte, force bool) ([]byte, int, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if raw == nil {
+		return nil, 0, fmt.Errorf("解析配置文件失败: 配置不是 JSON 对象")
+	}
+
+	fromVersion := configSchemaVersion(raw)
+	if fromVersion > CurrentConfigSchemaVersion {
+		if !force {
+			return nil, fromVersion, fmt.Errorf("%w (版本 %d，当前支持 %d)，请升级 Orbit 或使用 --force-config 强制加载",
+				errNewerConfigSchema, fromVersion, CurrentConfigSchemaVersion)
+		}
+		logger.Warnf("强制加载版本 %d 的配置，保存时新版本特有的字段会丢失", fromVersion)
+		return data, fromVersion, nil
+	}
+	if fromVersion == CurrentConfigSchemaVersion {
+		return data, fromVersion, nil
+	}
+
+	for _, migration := range configMigrations {
+		if migration.From < fromVersion {
+			continue
+		}
+		logger.Infof("迁移配置 v%d -> v%d: %s", migration.From, migration.From+1, migration.Description)
+		if err := migration.Migrate(raw); err != nil {
+			return nil, fromVersion, fmt.Errorf("配置迁移 v%d -> v%d 失败: %w", migration.From, migration.From+1, err)
+		}
+		raw["schema_version"] = migration.From + 1
+	}
+
+	if configSchemaVersion(raw) != CurrentConfigSchemaVersion {
+		return nil, fromVersion, fmt.Errorf("缺少从版本 %d 开始的配置迁移", configSchemaVersion(raw))
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fromVersion, err
+	}
+	return migrated, fromVersion, nil
+}
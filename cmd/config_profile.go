@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultProfileName 是 info.json 顶层配置对应的 profile，所有 profile 最终都继承自它
+const DefaultProfileName = "default"
+
+// 通过 --profile 指定的 profile，为空时使用配置文件中的 active_profile
+var profileName string
+
+// ConfigProfile 是保存在 info.json 中的命名 profile。
+// Values 只保存与父 profile 不同的值，其余值从 Inherits 指定的 profile 继承。
+type ConfigProfile struct {
+	Inherits string                 `json:"inherits,omitempty"`
+	Values   map[string]interface{} `json:"values,omitempty"`
+}
+
+// parentName 返回父 profile 名称，未指定时为 default
+func (p ConfigProfile) parentName() string {
+	if p.Inherits == "" {
+		return DefaultProfileName
+	}
+	return p.Inherits
+}
+
+// 不参与 profile 继承的元数据字段
+var profileMetaKeys = []string{"schema_version", "active_profile", "profiles", "last_update"}
+
+// configToValues 把配置转换为去掉元数据字段的 map
+func configToValues(config *UserConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	for _, key := range profileMetaKeys {
+		delete(values, key)
+	}
+	return values, nil
+}
+
+// mergeValues 把 overlay 深度合并到 base 中，对象逐字段合并，其他值整体替换
+func mergeValues(base, overlay map[string]interface{}) {
+	for key, value := range overlay {
+		overlayMap, isMap := value.(map[string]interface{})
+		baseMap, baseIsMap := base[key].(map[string]interface{})
+		if isMap && baseIsMap {
+			mergeValues(baseMap, overlayMap)
+			continue
+		}
+		base[key] = value
+	}
+}
+
+// diffValues 返回 child 中与 parent 不同的值
+func diffValues(parent, child map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for key, value := range child {
+		childMap, isMap := value.(map[string]interface{})
+		parentMap, parentIsMap := parent[key].(map[string]interface{})
+		if isMap && parentIsMap {
+			if nested := diffValues(parentMap, childMap); len(nested) > 0 {
+				diff[key] = nested
+			}
+			continue
+		}
+		if !reflect.DeepEqual(parent[key], value) {
+			diff[key] = value
+		}
+	}
+	return diff
+}
+
+// profileChain 返回从 default 到 name 的继承链（不含 default）
+func profileChain(root *UserConfig, name string) ([]string, error) {
+	var chain []string
+	visited := make(map[string]bool)
+
+	for name != DefaultProfileName {
+		if visited[name] {
+			return nil, fmt.Errorf("profile %s 存在循环继承", name)
+		}
+		visited[name] = true
+
+		profile, ok := root.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %s 不存在", name)
+		}
+		chain = append([]string{name}, chain...)
+		name = profile.parentName()
+	}
+	return chain, nil
+}
+
+// resolveProfileValues 计算 profile 合并后的配置值
+func resolveProfileValues(root *UserConfig, name string) (map[string]interface{}, error) {
+	chain, err := profileChain(root, name)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := configToValues(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range chain {
+		// 经过 JSON 往返，避免合并时修改 root 中的 map
+		overlay, err := cloneValues(root.Profiles[p].Values)
+		if err != nil {
+			return nil, err
+		}
+		// 旧版本写入 profile 的状态计数不再生效，以顶层配置为准
+		deleteReadOnlyValues(overlay)
+		mergeValues(values, overlay)
+	}
+	return values, nil
+}
+
+// resolveProfile 返回 profile 的有效配置，元数据字段与 root 一致
+func resolveProfile(root *UserConfig, name string) (*UserConfig, error) {
+	values, err := resolveProfileValues(root, name)
+	if err != nil {
+		return nil, err
+	}
+	return valuesToConfig(root, values)
+}
+
+// valuesToConfig 把合并后的配置值转换回 UserConfig，元数据字段取自 root
+func valuesToConfig(root *UserConfig, values map[string]interface{}) (*UserConfig, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var config UserConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	config.SchemaVersion = root.SchemaVersion
+	config.LastUpdate = root.LastUpdate
+	return &config, nil
+}
+
+// applyProfileUpdate 把 profile 的新有效配置写回 root：
+// default 直接替换顶层字段，其他 profile 只保存与父 profile 的差异。
+// 备份计数等只读状态属于本机，总是写入顶层字段，不进入 profile 的差异。
+func applyProfileUpdate(root *UserConfig, name string, updated *UserConfig) error {
+	if name == DefaultProfileName {
+		activeProfile, profiles := root.ActiveProfile, root.Profiles
+		*root = *updated
+		root.ActiveProfile, root.Profiles = activeProfile, profiles
+		return nil
+	}
+
+	for _, key := range configKeyRegistry {
+		if key.ReadOnly {
+			key.Set(root, key.Get(updated))
+		}
+	}
+
+	profile := root.Profiles[name]
+	parentValues, err := resolveProfileValues(root, profile.parentName())
+	if err != nil {
+		return err
+	}
+	values, err := configToValues(updated)
+	if err != nil {
+		return err
+	}
+
+	profile.Values = diffValues(parentValues, values)
+	deleteReadOnlyValues(profile.Values)
+	root.Profiles[name] = profile
+	return nil
+}
+
+// deleteReadOnlyValues 删除 profile 值中的只读状态键，以及因此变空的对象
+func deleteReadOnlyValues(values map[string]interface{}) {
+	for _, key := range configKeyRegistry {
+		if !key.ReadOnly {
+			continue
+		}
+		deleteValue(values, key.Key)
+
+		parts := strings.Split(key.Key, ".")
+		for i := len(parts) - 1; i > 0; i-- {
+			parent := strings.Join(parts[:i], ".")
+			nested, ok := lookupValue(values, parent)
+			if m, isMap := nested.(map[string]interface{}); !ok || !isMap || len(m) > 0 {
+				break
+			}
+			deleteValue(values, parent)
+		}
+	}
+}
+
+// cloneValues 深拷贝 profile 的值
+func cloneValues(values map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var clone map[string]interface{}
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// profileNames 返回包括 default 在内的所有 profile 名称
+func profileNames(root *UserConfig) []string {
+	names := []string{DefaultProfileName}
+	var others []string
+	for name := range root.Profiles {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// selectedProfile 返回当前使用的 profile：--profile 优先，其次是 active_profile
+func selectedProfile(root *UserConfig, override string) string {
+	if override != "" {
+		return override
+	}
+	if root.ActiveProfile != "" {
+		return root.ActiveProfile
+	}
+	return DefaultProfileName
+}
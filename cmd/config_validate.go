@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 配置问题的严重程度
+const (
+	SeverityError   = "error"   // 会导致命令失败，更新配置时不允许引入
+	SeverityWarning = "warning" // 可能是预期之内的，例如在另一台机器上还未复制密钥
+)
+
+// 配置问题代码，config repair --code 使用这些代码
+const (
+	IssueBackupPathEmpty     = "backup_path_empty"
+	IssueBackupPathMissing   = "backup_path_missing"
+	IssueVSCodeDirsEmpty     = "vscode_dirs_empty"
+	IssueVSCodeDirMissing    = "vscode_dir_missing"
+	IssueKeyPathEmpty        = "encryption_key_path_empty"
+	IssuePublicKeyMissing    = "encryption_public_key_missing"
+	IssuePrivateKeyMissing   = "encryption_private_key_missing"
+	IssueUnknownAlgorithm    = "encryption_unknown_algorithm"
+	IssueDuplicateListItems  = "duplicate_list_items"
+	IssueEmptyExcludePattern = "empty_exclude_pattern"
+	IssueBadExcludePattern   = "invalid_exclude_pattern"
+	IssueCatalogKeyMissing   = "catalog_public_key_missing"
+)
+
+// 支持的加密算法
+var supportedAlgorithms = []string{"RSA-2048", "RSA-4096"}
+
+// ConfigIssue 是一项配置检查结果
+type ConfigIssue struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+}
+
+// validateUserConfig 检查配置并返回所有问题，加载、更新、config validate 和 config repair 共用
+func validateUserConfig(config *UserConfig) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(field, severity, code, fix, format string, args ...interface{}) {
+		issues = append(issues, ConfigIssue{
+			Field:    field,
+			Severity: severity,
+			Code:     code,
+			Message:  fmt.Sprintf(format, args...),
+			Fix:      fix,
+		})
+	}
+
+	// 验证系统配置
+	if config.System.DefaultBackupPath == "" {
+		add("system.default_backup_path", SeverityError, IssueBackupPathEmpty,
+			"设置为当前目录", "默认备份路径为空")
+	} else if _, err := os.Stat(config.System.DefaultBackupPath); os.IsNotExist(err) {
+		add("system.default_backup_path", SeverityWarning, IssueBackupPathMissing,
+			"创建该目录", "默认备份路径不存在: %s", config.System.DefaultBackupPath)
+	}
+
+	// 验证VSCode配置
+	if len(config.VSCode.ConfigDirs) == 0 {
+		add("vscode.config_dirs", SeverityError, IssueVSCodeDirsEmpty,
+			"恢复默认的 VSCode 配置目录", "VSCode配置目录为空")
+	}
+	for i, dir := range config.VSCode.ConfigDirs {
+		if _, err := os.Stat(dir.Path); os.IsNotExist(err) {
+			add(fmt.Sprintf("vscode.config_dirs[%d].path", i), SeverityWarning, IssueVSCodeDirMissing,
+				"", "VSCode配置目录不存在: %s", dir.Path)
+		}
+	}
+	if dups := duplicateItems(config.VSCode.ExcludedExtensions); len(dups) > 0 {
+		add("vscode.excluded_extensions", SeverityWarning, IssueDuplicateListItems,
+			"删除重复项", "排除扩展中有重复项: %s", strings.Join(dups, ", "))
+	}
+
+	// 验证软件配置
+	if dups := duplicateItems(config.Software.ExcludedPatterns); len(dups) > 0 {
+		add("software.excluded_patterns", SeverityWarning, IssueDuplicateListItems,
+			"删除重复项", "排除模式中有重复项: %s", strings.Join(dups, ", "))
+	}
+	for _, pattern := range config.Software.ExcludedPatterns {
+		if strings.TrimSpace(pattern) == "" {
+			add("software.excluded_patterns", SeverityWarning, IssueEmptyExcludePattern,
+				"删除空模式", "排除模式中有空字符串")
+			break
+		}
+	}
+	if invalid := invalidSkipPatterns(config.Software.ExcludedPatterns); len(invalid) > 0 {
+		add("software.excluded_patterns", SeverityWarning, IssueBadExcludePattern,
+			"删除无效的模式", "排除模式无效，扫描软件时会被忽略: %s", strings.Join(invalid, ", "))
+	}
+
+	if len(config.Software.CatalogSources) > 0 {
+		if config.Software.CatalogPublicKey == "" {
+			add("software.catalog_public_key", SeverityWarning, IssueCatalogKeyMissing,
+				"", "配置了软件目录来源但没有设置验证签名的公钥，所有来源都会被拒绝")
+		} else if _, err := os.Stat(config.Software.CatalogPublicKey); os.IsNotExist(err) {
+			add("software.catalog_public_key", SeverityWarning, IssueCatalogKeyMissing,
+				"", "目录公钥文件不存在: %s", config.Software.CatalogPublicKey)
+		}
+	}
+
+	// 验证加密配置
+	if config.Encryption.Enabled {
+		if config.Encryption.PublicKeyPath == "" {
+			add("encryption.public_key_path", SeverityError, IssueKeyPathEmpty,
+				"关闭加密，或运行 orbit gen-keys", "启用加密但公钥路径为空")
+		} else if _, err := os.Stat(config.Encryption.PublicKeyPath); os.IsNotExist(err) {
+			add("encryption.public_key_path", SeverityWarning, IssuePublicKeyMissing,
+				"在常见位置查找公钥文件", "公钥文件不存在: %s", config.Encryption.PublicKeyPath)
+		}
+
+		if config.Encryption.PrivateKeyPath == "" {
+			add("encryption.private_key_path", SeverityError, IssueKeyPathEmpty,
+				"关闭加密，或运行 orbit gen-keys", "启用加密但私钥路径为空")
+		} else if _, err := os.Stat(config.Encryption.PrivateKeyPath); os.IsNotExist(err) {
+			add("encryption.private_key_path", SeverityWarning, IssuePrivateKeyMissing,
+				"在常见位置查找私钥文件", "私钥文件不存在: %s", config.Encryption.PrivateKeyPath)
+		}
+	}
+	if !containsString(supportedAlgorithms, config.Encryption.DefaultAlgorithm) {
+		add("encryption.default_algorithm", SeverityWarning, IssueUnknownAlgorithm,
+			"设置为 RSA-2048", "不支持的加密算法: %q", config.Encryption.DefaultAlgorithm)
+	}
+
+	return issues
+}
+
+// countIssues 统计错误和警告的数量
+func countIssues(issues []ConfigIssue) (errors, warnings int) {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// newConfigErrors 返回 after 中存在而 before 中没有的错误，用于拒绝引入新错误的更新，
+// 同时允许在已经有问题的配置上进行无关的修改
+func newConfigErrors(before, after *UserConfig) []ConfigIssue {
+	existing := make(map[string]bool)
+	if before != nil {
+		for _, issue := range validateUserConfig(before) {
+			existing[issue.Field+"/"+issue.Code] = true
+		}
+	}
+
+	var introduced []ConfigIssue
+	for _, issue := range validateUserConfig(after) {
+		if issue.Severity == SeverityError && !existing[issue.Field+"/"+issue.Code] {
+			introduced = append(introduced, issue)
+		}
+	}
+	return introduced
+}
+
+// issuesError 把问题列表合并为一个错误
+func issuesError(issues []ConfigIssue) error {
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = fmt.Sprintf("%s: %s", issue.Field, issue.Message)
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// configFixer 尝试修复一类问题，返回是否修复成功
+type configFixer func(config *UserConfig, issue ConfigIssue, configPath string) bool
+
+// configFixers 按问题代码注册的修复函数
+var configFixers = map[string]configFixer{
+	IssueBackupPathEmpty: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		config.System.DefaultBackupPath = CurrentDir
+		return true
+	},
+	IssueBackupPathMissing: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		if err := os.MkdirAll(config.System.DefaultBackupPath, 0755); err != nil {
+			logger.Warnf("创建备份目录失败: %v", err)
+			return false
+		}
+		return true
+	},
+	IssueVSCodeDirsEmpty: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		config.VSCode.ConfigDirs = []ConfigDirType{
+			{
+				Name:         "APPDATA",
+				Path:         CodeConfigDir,
+				OriginalPath: "%APPDATA%\\Code",
+			},
+			{
+				Name:         "USER",
+				Path:         CodeUserDir,
+				OriginalPath: "%USERPROFILE%\\.vscode",
+			},
+		}
+		return true
+	},
+	IssueKeyPathEmpty: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		config.Encryption.Enabled = false
+		return true
+	},
+	IssuePublicKeyMissing: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		path := findKeyFile(configPath, "public")
+		if path == "" {
+			return false
+		}
+		config.Encryption.PublicKeyPath = path
+		return true
+	},
+	IssuePrivateKeyMissing: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		path := findKeyFile(configPath, "private")
+		if path == "" {
+			return false
+		}
+		config.Encryption.PrivateKeyPath = path
+		return true
+	},
+	IssueUnknownAlgorithm: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		config.Encryption.DefaultAlgorithm = "RSA-2048"
+		return true
+	},
+	IssueDuplicateListItems: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		config.VSCode.ExcludedExtensions = uniqueItems(config.VSCode.ExcludedExtensions)
+		config.Software.ExcludedPatterns = uniqueItems(config.Software.ExcludedPatterns)
+		return true
+	},
+	IssueEmptyExcludePattern: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		var patterns []string
+		for _, pattern := range config.Software.ExcludedPatterns {
+			if strings.TrimSpace(pattern) != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+		config.Software.ExcludedPatterns = patterns
+		return true
+	},
+	IssueBadExcludePattern: func(config *UserConfig, issue ConfigIssue, configPath string) bool {
+		invalid := invalidSkipPatterns(config.Software.ExcludedPatterns)
+		config.Software.ExcludedPatterns, _ = removeListItems(config.Software.ExcludedPatterns, invalid)
+		return true
+	},
+}
+
+// findKeyFile 在常见位置查找 kind 为 public 或 private 的密钥文件
+func findKeyFile(configPath, kind string) string {
+	possiblePaths := []string{
+		filepath.Join(filepath.Dir(configPath), "keys", kind+".pem"),
+		filepath.Join(CurrentDir, kind+".pem"),
+		filepath.Join(CurrentDir, getWinUserName()+"_"+kind+"_key.pem"),
+	}
+	for _, path := range possiblePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// repairConfiguration 修复当前 profile 中的问题，codes 为空时修复所有可修复的问题。
+// 返回修复成功和仍未解决的问题。
+func repairConfiguration(configManager *ConfigManager, codes []string) ([]ConfigIssue, []ConfigIssue, error) {
+	var fixed, remaining []ConfigIssue
+
+	err := configManager.updateProfile(func(config, parent *UserConfig) error {
+		fixed, remaining = nil, nil
+		for _, issue := range validateUserConfig(config) {
+			fixer, ok := configFixers[issue.Code]
+			if !ok || (len(codes) > 0 && !containsString(codes, issue.Code)) {
+				remaining = append(remaining, issue)
+				continue
+			}
+			if fixer(config, issue, configManager.configPath) {
+				logger.Infof("修复 [%s] %s: %s", issue.Code, issue.Field, issue.Fix)
+				fixed = append(fixed, issue)
+			} else {
+				remaining = append(remaining, issue)
+			}
+		}
+		return nil
+	})
+	return fixed, remaining, err
+}
+
+// duplicateItems 返回列表中重复出现的项
+func duplicateItems(items []string) []string {
+	seen := make(map[string]int)
+	var dups []string
+	for _, item := range items {
+		seen[item]++
+		if seen[item] == 2 {
+			dups = append(dups, item)
+		}
+	}
+	return dups
+}
+
+// uniqueItems 去掉重复项并保持原有顺序
+func uniqueItems(items []string) []string {
+	unique, _ := addListItems([]string{}, items)
+	return unique
+}
+
+// containsString 判断 list 中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
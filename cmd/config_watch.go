@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 监视配置文件的默认轮询间隔和防抖时间
+const (
+	DefaultConfigWatchInterval = time.Second
+	DefaultConfigWatchDebounce = 500 * time.Millisecond
+)
+
+// ConfigChangeEvent 描述一次有效配置的变化
+type ConfigChangeEvent struct {
+	Keys   []string    // 变化的配置键（点分路径），只包含订阅的前缀之下的键
+	Config *UserConfig // 变化后的有效配置副本
+}
+
+// configSubscriber 是一个配置变化的订阅者
+type configSubscriber struct {
+	prefixes []string
+	callback func(ConfigChangeEvent)
+}
+
+// matches 返回 keys 中订阅者关心的键，没有指定前缀时返回全部
+func (s configSubscriber) matches(keys []string) []string {
+	if len(s.prefixes) == 0 {
+		return keys
+	}
+	var matched []string
+	for _, key := range keys {
+		for _, prefix := range s.prefixes {
+			if key == prefix || strings.HasPrefix(key, prefix+".") {
+				matched = append(matched, key)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Subscribe 注册配置变化的回调，prefixes 为空时关心所有键，例如 Subscribe(fn, "software") 只在
+// software.* 变化时调用。无论变化来自本进程的修改还是 Watch 检测到的外部编辑都会通知。
+// 回调在调用方的 goroutine 中执行，不持有配置锁。返回的函数用于取消订阅。
+func (cm *ConfigManager) Subscribe(callback func(ConfigChangeEvent), prefixes ...string) func() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.subscribers == nil {
+		cm.subscribers = make(map[int]configSubscriber)
+	}
+	id := cm.nextSubscriber
+	cm.nextSubscriber++
+	cm.subscribers[id] = configSubscriber{prefixes: prefixes, callback: callback}
+
+	return func() {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		delete(cm.subscribers, id)
+	}
+}
+
+// notifySubscribers 比较前后两份有效配置，把变化的键通知给订阅者；调用方不能持有 cm.mu
+func (cm *ConfigManager) notifySubscribers(previous, current *UserConfig) {
+	if previous == nil || current == nil {
+		return
+	}
+	changes, err := diffConfigs(previous, current)
+	if err != nil {
+		logger.Warnf("比较配置变化失败: %v", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	keys := make([]string, len(changes))
+	for i, change := range changes {
+		keys[i] = change.Key
+	}
+
+	cm.mu.RLock()
+	subscribers := make([]configSubscriber, 0, len(cm.subscribers))
+	for _, subscriber := range cm.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	cm.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		if matched := subscriber.matches(keys); len(matched) > 0 {
+			subscriber.callback(ConfigChangeEvent{Keys: matched, Config: cm.deepCopyConfig(current)})
+		}
+	}
+}
+
+// reloadFromDisk 重新读取配置文件。与 LoadConfig 不同，文件无法解析或引入了新的错误时
+// 保留当前配置并返回错误，不会回退到 last-good 备份，以免覆盖用户正在编辑的文件。
+func (cm *ConfigManager) reloadFromDisk() error {
+	previous, current, err := cm.swapFromDisk()
+	if err != nil {
+		return err
+	}
+	cm.notifySubscribers(previous, current)
+	return nil
+}
+
+// swapFromDisk 验证磁盘上的配置并替换当前配置，返回替换前后的有效配置
+func (cm *ConfigManager) swapFromDisk() (*UserConfig, *UserConfig, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.userConfig == nil {
+		return nil, nil, fmt.Errorf("配置未加载")
+	}
+
+	lock, err := lockFile(cm.configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("锁定配置文件失败: %w", err)
+	}
+	defer lock.Unlock()
+
+	data, err := os.ReadFile(cm.configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	config, err := cm.decodeConfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 外部编辑引入的错误不生效，已有的错误不影响重新加载
+	if issues := newConfigErrors(cm.userConfig, config); len(issues) > 0 {
+		return nil, nil, fmt.Errorf("配置验证失败: %w", issuesError(issues))
+	}
+	effective, err := cm.resolveEffective(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous := cm.effective
+	cm.userConfig = config
+	cm.effective = effective
+	cm.lastLoadTime = time.Now()
+	return previous, effective, nil
+}
+
+// configFileStamp 用于检测配置文件是否被修改
+type configFileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func (s configFileStamp) equal(other configFileStamp) bool {
+	return s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func statConfigFile(path string) configFileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileStamp{}
+	}
+	return configFileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// Watch 轮询配置文件，文件在 debounce 时间内不再变化后重新加载并通知订阅者，直到 ctx 取消。
+// 使用轮询而不是文件系统通知，因为编辑器通常通过 重命名 替换文件，而且不需要额外的依赖。
+func (cm *ConfigManager) Watch(ctx context.Context, interval, debounce time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultConfigWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := statConfigFile(cm.configPath)
+	var changedAt time.Time // 最近一次检测到变化的时间，零值表示没有待处理的变化
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if stamp := statConfigFile(cm.configPath); !stamp.equal(last) {
+				last = stamp
+				changedAt = now
+				continue
+			}
+			if changedAt.IsZero() || now.Sub(changedAt) < debounce {
+				continue
+			}
+			changedAt = time.Time{}
+
+			if !last.exists {
+				logger.Warnf("配置文件已被删除，继续使用当前配置: %s", cm.configPath)
+				continue
+			}
+			if err := cm.reloadFromDisk(); err != nil {
+				logger.Warnf("配置文件已修改但未重新加载，继续使用当前配置: %v", err)
+				continue
+			}
+			// 迁移可能会重写文件，以重新加载后的状态为准
+			last = statConfigFile(cm.configPath)
+		}
+	}
+}
+
+// 监视配置文件时的轮询间隔和防抖时间
+var (
+	watchInterval time.Duration
+	watchDebounce time.Duration
+)
+
+// configWatchCmd 监视配置文件并输出变化
+var configWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch the configuration file and report changes",
+	Long: `Watch info.json for external edits and reload it, printing every changed key.
+
+An edit is picked up once the file has stopped changing for the debounce
+period. Edits that cannot be parsed or that introduce validation errors are
+reported and ignored; the previous configuration stays in effect. Press
+Ctrl+C to stop.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		unsubscribe := configManager.Subscribe(func(event ConfigChangeEvent) {
+			logger.Infof("配置已重新加载，变化的配置键: %s", strings.Join(event.Keys, ", "))
+		})
+		defer unsubscribe()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		logger.Infof("正在监视 %s (按 Ctrl+C 停止)", configManager.configPath)
+		if err := configManager.Watch(ctx, watchInterval, watchDebounce); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Errorf("监视配置文件失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configWatchCmd)
+
+	configWatchCmd.Flags().DurationVar(&watchInterval, "interval", DefaultConfigWatchInterval, "How often to check the file for changes")
+	configWatchCmd.Flags().DurationVar(&watchDebounce, "debounce", DefaultConfigWatchDebounce, "How long the file must stay unchanged before reloading")
+}
@@ -99,7 +99,12 @@ func LoadPublicKey(publicKeyPath string) (*rsa.PublicKey, error) {
 
 // CreateEncryptedOrbitFile creates an encrypted .orbit file with proper structure
 func CreateEncryptedOrbitFile(encryptedSymmetricKey, encryptedData []byte) error {
-	backupFile, err := os.Create("backup.orbit")
+	return WriteEncryptedOrbitFile("backup.orbit", encryptedSymmetricKey, encryptedData)
+}
+
+// WriteEncryptedOrbitFile writes an encrypted .orbit file to the given path
+func WriteEncryptedOrbitFile(orbitFilePath string, encryptedSymmetricKey, encryptedData []byte) error {
+	backupFile, err := os.Create(orbitFilePath)
 	if err != nil {
 		return err
 	}
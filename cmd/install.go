@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -23,6 +27,9 @@ type DesiredSoftware struct {
 	SilentArgs  string `json:"silentArgs"`
 	Version     string `json:"version"`
 	Category    string `json:"category"`
+	// Match overrides how the entry is recognised among installed software;
+	// by default it matches installed entries with the same normalized name
+	Match *SoftwareMatch `json:"match,omitempty"`
 }
 
 // SoftwareBlacklist represents software that should not be installed
@@ -37,12 +44,35 @@ type SoftwareCatalog struct {
 	Software  []DesiredSoftware `json:"software"`
 }
 
+// installFrom is the backup whose software list drives the installation
+var (
+	installFrom       string
+	installPrivateKey string // decrypts an encrypted --from backup
+)
+
 var installCmd = &cobra.Command{
-	Use:   "install",
+	Use:   "install [name]",
 	Short: "Install missing software from catalog",
 	Long: `Install software that is in the catalog but not currently installed on the system.
-Supports blacklist to exclude specific software from installation.`,
+Supports blacklist to exclude specific software from installation.
+
+With --from, install the apps listed in a backup's software-list.json instead;
+see 'orbit plan' for what will be installed.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if installFrom != "" {
+			if len(args) > 0 {
+				logger.Error("--from cannot be combined with a software name")
+				os.Exit(1)
+			}
+			// Install the software from a backup
+			if err := installFromBackup(cmd.Context(), installFrom, installPrivateKey); err != nil {
+				logger.Errorf("Failed to install from %s: %v", installFrom, err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		if len(args) > 0 {
 			// Install specific software
 			installSpecificSoftware(args[0])
@@ -55,33 +85,20 @@ Supports blacklist to exclude specific software from installation.`,
 
 func init() {
 	rootCmd.AddCommand(installCmd)
+
+	installCmd.Flags().StringVar(&installFrom, "from", "", "Install the software listed in this .orbit backup")
+	installCmd.Flags().StringVar(&installPrivateKey, "private-key", "", "Private key for an encrypted --from backup (default: encryption.private_key_path)")
 }
 
 // installSpecificSoftware installs a specific software by name
 func installSpecificSoftware(softwareName string) {
 	logger.Infof("Attempting to install: %s", softwareName)
 
-	// Check if already installed
-	installed, err := getInstalledSoftware()
-	if err != nil {
-		logger.Errorf("Failed to get installed software: %v", err)
-		return
-	}
-
-	for _, sw := range installed {
-		if strings.EqualFold(sw.Name, softwareName) {
-			logger.Infof("Software '%s' is already installed", softwareName)
-			return
-		}
-	}
-
 	// Check blacklist
 	blacklist, _ := loadBlacklist()
-	for _, blacklisted := range blacklist.Software {
-		if strings.EqualFold(blacklisted, softwareName) {
-			logger.Warnf("Software '%s' is in blacklist and will not be installed", softwareName)
-			return
-		}
+	if isBlacklisted(blacklist, softwareName) {
+		logger.Warnf("Software '%s' is in blacklist and will not be installed", softwareName)
+		return
 	}
 
 	// Load software catalog
@@ -91,11 +108,11 @@ func installSpecificSoftware(softwareName string) {
 		return
 	}
 
-	// Find the software in catalog
+	// Find the software in catalog, by name or by one of its aliases
 	var targetSoftware *DesiredSoftware
-	for _, sw := range catalog.Software {
-		if strings.EqualFold(sw.Name, softwareName) {
-			targetSoftware = &sw
+	for i, sw := range catalog.Software {
+		if catalogEntryNamed(sw, softwareName) {
+			targetSoftware = &catalog.Software[i]
 			break
 		}
 	}
@@ -105,6 +122,23 @@ func installSpecificSoftware(softwareName string) {
 		return
 	}
 
+	// Check if already installed
+	installed, err := getAllInstalledSoftware(context.Background())
+	if err != nil {
+		logger.Errorf("Failed to get installed software: %v", err)
+		return
+	}
+
+	match, reason, err := findInstalled(*targetSoftware, installed)
+	if err != nil {
+		logger.Errorf("Failed to match installed software: %v", err)
+		return
+	}
+	if match != nil {
+		logger.Infof("Software '%s' is already installed as '%s' (%s)", softwareName, match.Name, reason)
+		return
+	}
+
 	// Download and install
 	if err := downloadAndInstall(*targetSoftware); err != nil {
 		logger.Errorf("Failed to install %s: %v", softwareName, err)
@@ -118,7 +152,7 @@ func installMissingSoftware() {
 	logger.Info("Scanning for missing software to install...")
 
 	// Get installed software
-	installed, err := getInstalledSoftware()
+	installed, err := getAllInstalledSoftware(context.Background())
 	if err != nil {
 		logger.Errorf("Failed to get installed software: %v", err)
 		return
@@ -137,28 +171,24 @@ func installMissingSoftware() {
 	// Find missing software
 	var missingSoftware []DesiredSoftware
 	for _, desired := range catalog.Software {
-		found := false
+		// Check if blacklisted
+		if isBlacklisted(blacklist, desired.Name) {
+			logger.Infof("Skipping blacklisted software: %s", desired.Name)
+			continue
+		}
 
 		// Check if already installed
-		for _, inst := range installed {
-			if strings.EqualFold(inst.Name, desired.Name) {
-				found = true
-				break
-			}
+		match, reason, err := findInstalled(desired, installed)
+		if err != nil {
+			logger.Warnf("Skipping %s: %v", desired.Name, err)
+			continue
 		}
-
-		// Check if blacklisted
-		for _, blacklisted := range blacklist.Software {
-			if strings.EqualFold(blacklisted, desired.Name) {
-				found = true // Treat as "found" to skip installation
-				logger.Infof("Skipping blacklisted software: %s", desired.Name)
-				break
-			}
+		if match != nil {
+			logger.Debugf("%s is installed as '%s' (%s)", desired.Name, match.Name, reason)
+			continue
 		}
 
-		if !found {
-			missingSoftware = append(missingSoftware, desired)
-		}
+		missingSoftware = append(missingSoftware, desired)
 	}
 
 	if len(missingSoftware) == 0 {
@@ -179,6 +209,31 @@ func installMissingSoftware() {
 	}
 }
 
+// catalogEntryNamed reports whether a catalog entry goes by the given name or alias
+func catalogEntryNamed(desired DesiredSoftware, name string) bool {
+	if sameSoftwareName(desired.Name, name) {
+		return true
+	}
+	if desired.Match != nil {
+		for _, alias := range desired.Match.Aliases {
+			if sameSoftwareName(alias, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isBlacklisted reports whether the blacklist contains the software, comparing normalized names
+func isBlacklisted(blacklist *SoftwareBlacklist, name string) bool {
+	for _, blacklisted := range blacklist.Software {
+		if sameSoftwareName(blacklisted, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // downloadAndInstall downloads and installs a software
 func downloadAndInstall(software DesiredSoftware) error {
 	// Create temp directory for downloads
@@ -198,7 +253,7 @@ func downloadAndInstall(software DesiredSoftware) error {
 	}
 
 	// Download installer
-	installerPath := filepath.Join(tempDir, filepath.Base(downloadURL))
+	installerPath := filepath.Join(tempDir, installerFileName(software, downloadURL))
 	logger.Infof("Downloading %s from %s", software.Name, downloadURL)
 
 	if err := downloadFile(downloadURL, installerPath); err != nil {
@@ -217,6 +272,27 @@ func downloadAndInstall(software DesiredSoftware) error {
 	return nil
 }
 
+// unsafeFileNameRegexp matches characters replaced when a software name is used as a file name
+var unsafeFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// installerFileName returns the local file name of a downloaded installer. URLs such as
+// "https://download.mozilla.org/?product=firefox-latest" do not end in an installer file
+// name, so the name then comes from the software name and its Installer type.
+func installerFileName(software DesiredSoftware, downloadURL string) string {
+	if u, err := url.Parse(downloadURL); err == nil {
+		name := path.Base(u.Path)
+		switch strings.ToLower(path.Ext(name)) {
+		case ".msi", ".exe":
+			return name
+		}
+	}
+	name := strings.Trim(unsafeFileNameRegexp.ReplaceAllString(software.Name, "_"), "_")
+	if software.Installer != "" {
+		name += "." + strings.ToLower(software.Installer)
+	}
+	return name
+}
+
 // 进度跟踪器，实现io.Writer接口
 type ProgressTracker struct {
 	TotalSize  int64
@@ -331,25 +407,20 @@ func runInstaller(installerPath, silentArgs string) error {
 	return nil
 }
 
-// loadSoftwareCatalog loads the software catalog from file
+// loadSoftwareCatalog loads the merged software catalog: software-catalog.json in the
+// current directory, then the configured catalog sources, see catalogLoader. It fails
+// when the local catalog cannot be parsed or every configured source failed.
 func loadSoftwareCatalog() (*SoftwareCatalog, error) {
-	catalogPath := "software-catalog.json"
-	if _, err := os.Stat(catalogPath); os.IsNotExist(err) {
-		// Create default catalog if it doesn't exist
-		return createDefaultCatalog(), nil
-	}
-
-	data, err := os.ReadFile(catalogPath)
+	entries, _, err := loadCatalogEntries(context.Background(), false)
 	if err != nil {
 		return nil, err
 	}
 
-	var catalog SoftwareCatalog
-	if err := json.Unmarshal(data, &catalog); err != nil {
-		return nil, err
+	catalog := &SoftwareCatalog{Timestamp: time.Now().Format(time.RFC3339)}
+	for _, entry := range entries {
+		catalog.Software = append(catalog.Software, entry.DesiredSoftware)
 	}
-
-	return &catalog, nil
+	return catalog, nil
 }
 
 // loadBlacklist loads the software blacklist from file
@@ -373,7 +444,9 @@ func loadBlacklist() (*SoftwareBlacklist, error) {
 	return &blacklist, nil
 }
 
-// createDefaultCatalog creates a default software catalog with common applications
+// createDefaultCatalog creates a default software catalog with common applications. Only
+// download links that always point to the latest release belong here; installers whose
+// URL contains a version go stale and should be published in a catalog source instead.
 func createDefaultCatalog() *SoftwareCatalog {
 	return &SoftwareCatalog{
 		Timestamp: time.Now().Format(time.RFC3339),
@@ -402,22 +475,6 @@ func createDefaultCatalog() *SoftwareCatalog {
 				SilentArgs:  "/SILENT /MERGETASKS=!runcode",
 				Category:    "Development",
 			},
-			{
-				Name:        "7-Zip",
-				OfficialURL: "https://www.7-zip.org/",
-				DownloadURL: "https://www.7-zip.org/a/7z2301-x64.exe",
-				Installer:   "exe",
-				SilentArgs:  "/S",
-				Category:    "Utilities",
-			},
-			{
-				Name:        "Notepad++",
-				OfficialURL: "https://notepad-plus-plus.org/",
-				DownloadURL: "https://github.com/notepad-plus-plus/notepad-plus-plus/releases/download/v8.6.4/npp.8.6.4.Installer.x64.exe",
-				Installer:   "exe",
-				SilentArgs:  "/S",
-				Category:    "Text Editor",
-			},
 		},
 	}
 }
@@ -0,0 +1,9 @@
+package cmd
+
+// inventorySources lists the inventory sources used on macOS
+func inventorySources(config SoftwareConfig) []InventorySource {
+	return []InventorySource{
+		applicationsSource{Dirs: defaultApplicationDirs()},
+		homebrewSource{Prefixes: defaultHomebrewPrefixes},
+	}
+}
@@ -0,0 +1,11 @@
+package cmd
+
+// inventorySources lists the inventory sources used on Linux
+func inventorySources(config SoftwareConfig) []InventorySource {
+	return []InventorySource{
+		dpkgSource{StatusPath: defaultDpkgStatusPath},
+		rpmSource{},
+		flatpakSource{},
+		snapSource{},
+	}
+}
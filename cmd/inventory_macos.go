@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultHomebrewPrefixes are the Homebrew prefixes on Apple Silicon and Intel Macs
+var defaultHomebrewPrefixes = []string{"/opt/homebrew", "/usr/local"}
+
+// defaultApplicationDirs returns /Applications and ~/Applications
+func defaultApplicationDirs() []string {
+	dirs := []string{"/Applications"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Applications"))
+	}
+	return dirs
+}
+
+// applicationsSource lists the .app bundles in the Applications folders, including
+// bundles one level down such as /Applications/Utilities
+type applicationsSource struct {
+	Dirs []string
+}
+
+func (applicationsSource) Name() string { return "applications" }
+
+func (s applicationsSource) Available() bool {
+	for _, dir := range s.Dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s applicationsSource) Collect(ctx context.Context) ([]Software, error) {
+	var software []Software
+	for _, dir := range s.Dirs {
+		bundles, err := findAppBundles(dir, 1)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, bundle := range bundles {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			software = append(software, readAppBundle(bundle))
+		}
+	}
+	return software, nil
+}
+
+// findAppBundles returns the .app directories in dir, descending at most depth
+// levels into folders that are not bundles themselves
+func findAppBundles(dir string, depth int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundles []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if strings.HasSuffix(entry.Name(), ".app") {
+			bundles = append(bundles, path)
+		} else if depth > 0 {
+			nested, err := findAppBundles(path, depth-1)
+			if err == nil {
+				bundles = append(bundles, nested...)
+			}
+		}
+	}
+	return bundles, nil
+}
+
+// readAppBundle describes a bundle from its Info.plist, falling back to the folder
+// name when the plist is missing or in binary format
+func readAppBundle(bundle string) Software {
+	item := Software{
+		Name:        strings.TrimSuffix(filepath.Base(bundle), ".app"),
+		InstallPath: bundle,
+		Source:      "applications",
+	}
+
+	data, err := os.ReadFile(filepath.Join(bundle, "Contents", "Info.plist"))
+	if err != nil {
+		return item
+	}
+	values, err := parsePlistStrings(data)
+	if err != nil {
+		return item
+	}
+
+	if name := values["CFBundleDisplayName"]; name != "" {
+		item.Name = name
+	} else if name := values["CFBundleName"]; name != "" {
+		item.Name = name
+	}
+	item.ID = values["CFBundleIdentifier"]
+	item.Version = values["CFBundleShortVersionString"]
+	if item.Version == "" {
+		item.Version = values["CFBundleVersion"]
+	}
+	return item
+}
+
+// parsePlistStrings returns the string values of the top-level dict of an XML plist
+func parsePlistStrings(data []byte) (map[string]string, error) {
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return nil, fmt.Errorf("binary plist is not supported")
+	}
+
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Some plists declare encodings other than UTF-8; the values used here are ASCII
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	depth := 0
+	key := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "dict", "array":
+				depth++
+			case "key", "string":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return nil, err
+				}
+				if depth != 1 {
+					continue
+				}
+				if t.Name.Local == "key" {
+					key = text
+				} else if key != "" {
+					values[key] = strings.TrimSpace(text)
+					key = ""
+				}
+			default:
+				// Values of other types end the pending key
+				if depth == 1 {
+					key = ""
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "dict" || t.Name.Local == "array" {
+				depth--
+			}
+		}
+	}
+}
+
+// homebrewSource lists formulae in the Cellar and casks in the Caskroom of each prefix
+type homebrewSource struct {
+	Prefixes []string
+}
+
+func (homebrewSource) Name() string { return "homebrew" }
+
+func (s homebrewSource) Available() bool {
+	for _, prefix := range s.Prefixes {
+		if _, err := os.Stat(filepath.Join(prefix, "Cellar")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s homebrewSource) Collect(ctx context.Context) ([]Software, error) {
+	var software []Software
+	for _, prefix := range s.Prefixes {
+		software = append(software, listHomebrewDir(filepath.Join(prefix, "Cellar"), "homebrew")...)
+		software = append(software, listHomebrewDir(filepath.Join(prefix, "Caskroom"), "homebrew-cask")...)
+	}
+	return software, nil
+}
+
+// listHomebrewDir lists <dir>/<name>/<version> entries, using the newest version
+// when several are installed side by side
+func listHomebrewDir(dir, source string) []Software {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var software []Software
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		var names []string
+		for _, version := range versions {
+			if version.IsDir() && !strings.HasPrefix(version.Name(), ".") {
+				names = append(names, version.Name())
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Slice(names, func(i, j int) bool { return compareVersions(names[i], names[j]) < 0 })
+
+		software = append(software, Software{
+			Name:        entry.Name(),
+			ID:          entry.Name(),
+			Version:     names[len(names)-1],
+			InstallPath: filepath.Join(dir, entry.Name(), names[len(names)-1]),
+			Source:      source,
+		})
+	}
+	return software
+}
+
+// compareVersions compares two version strings segment by segment, numerically where
+// both segments are numbers, so that 2.10.0 is newer than 2.9.0 and 1.2.3_1 (a
+// Homebrew revision) is newer than 1.2.3. It returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool {
+			return r == '.' || r == '_' || r == '-' || r == ',' || r == '+'
+		})
+	}
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
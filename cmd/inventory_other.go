@@ -0,0 +1,8 @@
+//go:build !windows && !linux && !darwin
+
+package cmd
+
+// inventorySources has no sources on platforms without a known package database
+func inventorySources(config SoftwareConfig) []InventorySource {
+	return nil
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDpkgStatusPath is the dpkg database on Debian and Ubuntu
+const defaultDpkgStatusPath = "/var/lib/dpkg/status"
+
+// dpkgSource reads packages installed with dpkg/apt from the dpkg status file
+type dpkgSource struct {
+	StatusPath string
+}
+
+func (dpkgSource) Name() string { return "dpkg" }
+
+func (s dpkgSource) Available() bool {
+	_, err := os.Stat(s.StatusPath)
+	return err == nil
+}
+
+func (s dpkgSource) Collect(ctx context.Context) ([]Software, error) {
+	data, err := os.ReadFile(s.StatusPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dpkg status: %v", err)
+	}
+	return parseDpkgStatus(data), nil
+}
+
+// parseDpkgStatus parses the RFC 822 style stanzas of /var/lib/dpkg/status and
+// returns the packages whose status is "installed"
+func parseDpkgStatus(data []byte) []Software {
+	var software []Software
+	for _, stanza := range bytes.Split(data, []byte("\n\n")) {
+		fields := parseControlStanza(string(stanza))
+		if fields["Package"] == "" || !strings.HasSuffix(fields["Status"], " installed") {
+			continue
+		}
+		software = append(software, Software{
+			Name:      fields["Package"],
+			ID:        fields["Package"],
+			Version:   fields["Version"],
+			Publisher: fields["Maintainer"],
+			Source:    "dpkg",
+		})
+	}
+	return software
+}
+
+// parseControlStanza returns the fields of one control file stanza; continuation
+// lines (starting with a space) are ignored since only single-line fields are used
+func parseControlStanza(stanza string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(stanza, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[name] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// rpmQueryFormat makes rpm print one tab separated line per package
+const rpmQueryFormat = `%{NAME}\t%{VERSION}-%{RELEASE}\t%{VENDOR}\t%{INSTALLTIME}\n`
+
+// rpmSource lists packages from the rpm database on Fedora, RHEL and openSUSE
+type rpmSource struct{}
+
+func (rpmSource) Name() string { return "rpm" }
+
+func (rpmSource) Available() bool { return commandAvailable("rpm") }
+
+func (rpmSource) Collect(ctx context.Context) ([]Software, error) {
+	output, err := runInventoryCommand(ctx, "rpm", "-qa", "--queryformat", rpmQueryFormat)
+	if err != nil {
+		return nil, err
+	}
+	return parseRpmOutput(output), nil
+}
+
+// parseRpmOutput parses the output of rpm -qa with rpmQueryFormat
+func parseRpmOutput(output []byte) []Software {
+	var software []Software
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		// gpg-pubkey entries are imported signing keys, not packages
+		if len(fields) < 4 || fields[0] == "" || fields[0] == "gpg-pubkey" {
+			continue
+		}
+
+		item := Software{
+			Name:    fields[0],
+			ID:      fields[0],
+			Version: fields[1],
+			Source:  "rpm",
+		}
+		if fields[2] != "(none)" {
+			item.Publisher = fields[2]
+		}
+		if seconds, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			item.InstallDate = time.Unix(seconds, 0).UTC().Format("2006-01-02")
+		}
+		software = append(software, item)
+	}
+	return software
+}
+
+// flatpakSource lists Flatpak applications (runtimes are left out)
+type flatpakSource struct{}
+
+func (flatpakSource) Name() string { return "flatpak" }
+
+func (flatpakSource) Available() bool { return commandAvailable("flatpak") }
+
+func (flatpakSource) Collect(ctx context.Context) ([]Software, error) {
+	output, err := runInventoryCommand(ctx, "flatpak", "list", "--app", "--columns=name,application,version,origin")
+	if err != nil {
+		return nil, err
+	}
+	return parseFlatpakOutput(output), nil
+}
+
+// parseFlatpakOutput parses the tab separated output of flatpak list --columns=name,application,version,origin
+func parseFlatpakOutput(output []byte) []Software {
+	var software []Software
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 || (fields[0] == "Name" && fields[1] == "Application ID") {
+			continue
+		}
+
+		item := Software{Name: strings.TrimSpace(fields[0]), ID: strings.TrimSpace(fields[1]), Source: "flatpak"}
+		if item.Name == "" {
+			item.Name = item.ID
+		}
+		if len(fields) > 2 {
+			item.Version = strings.TrimSpace(fields[2])
+		}
+		software = append(software, item)
+	}
+	return software
+}
+
+// snapSource lists installed snaps, leaving out bases and snapd itself
+type snapSource struct{}
+
+func (snapSource) Name() string { return "snap" }
+
+func (snapSource) Available() bool { return commandAvailable("snap") }
+
+func (snapSource) Collect(ctx context.Context) ([]Software, error) {
+	output, err := runInventoryCommand(ctx, "snap", "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapOutput(output), nil
+}
+
+// parseSnapOutput parses the table printed by snap list:
+//
+//	Name     Version  Rev   Tracking       Publisher   Notes
+//	firefox  125.0    4173  latest/stable  mozilla✓    -
+func parseSnapOutput(output []byte) []Software {
+	var software []Software
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] == "Name" {
+			continue
+		}
+
+		if len(fields) > 5 && isSnapSystemNote(fields[5]) {
+			continue
+		}
+
+		// Verified and starred publishers are marked with ✓ and ✪
+		publisher := strings.TrimRight(fields[4], "✓✪*")
+		if publisher == "-" {
+			publisher = ""
+		}
+		software = append(software, Software{
+			Name:      fields[0],
+			ID:        fields[0],
+			Version:   fields[1],
+			Publisher: publisher,
+			Source:    "snap",
+		})
+	}
+	return software
+}
+
+// isSnapSystemNote reports whether the Notes column marks a base, core or snapd snap
+func isSnapSystemNote(notes string) bool {
+	for _, note := range strings.Split(notes, ",") {
+		switch note {
+		case "base", "core", "snapd":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Registry roots scanned for Uninstall keys
+const (
+	registryLocalMachine = "HKLM"
+	registryCurrentUser  = "HKCU"
+)
+
+// errRegistryValueNotFound is returned by registryKey when a value does not exist
+var errRegistryValueNotFound = errors.New("registry value not found")
+
+// registryKey is the subset of a registry key used by the inventory. It is
+// implemented by the real registry on Windows and by a fake hive in tests.
+type registryKey interface {
+	SubKeyNames() ([]string, error)
+	OpenSubKey(name string) (registryKey, error)
+	StringValue(name string) (string, error)
+	IntegerValue(name string) (uint64, error)
+	Close() error
+}
+
+// registryHive opens keys under HKLM or HKCU
+type registryHive interface {
+	OpenKey(root, path string) (registryKey, error)
+}
+
+// uninstallLocation is one Uninstall key and the scope of the software under it
+type uninstallLocation struct {
+	Root  string
+	Path  string
+	Scope string
+}
+
+// uninstallLocations lists where Windows installers register installed programs.
+// Per-user installs (VSCode user setup, Discord, most Electron apps) only appear under HKCU.
+var uninstallLocations = []uninstallLocation{
+	{registryLocalMachine, `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`, "machine"},
+	{registryLocalMachine, `SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`, "machine"},
+	{registryCurrentUser, `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`, "user"},
+}
+
+// registrySource reads traditional Windows programs from the Uninstall registry keys
+type registrySource struct {
+	Hive registryHive
+}
+
+func (registrySource) Name() string { return "registry" }
+
+func (s registrySource) Available() bool { return s.Hive != nil }
+
+// Collect retrieves installed software from the Uninstall keys of every location.
+// A location that cannot be opened (e.g. no WOW6432Node on 32-bit Windows) is skipped.
+func (s registrySource) Collect(ctx context.Context) ([]Software, error) {
+	var software []Software
+	opened := 0
+
+	for _, location := range uninstallLocations {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		items, err := readUninstallKey(s.Hive, location)
+		if err != nil {
+			logger.Debugf("Skipping %s\\%s: %v", location.Root, location.Path, err)
+			continue
+		}
+		opened++
+		software = append(software, items...)
+	}
+
+	if opened == 0 {
+		return nil, fmt.Errorf("no Uninstall registry key could be opened")
+	}
+	return software, nil
+}
+
+// readUninstallKey lists the programs registered under one Uninstall key
+func readUninstallKey(hive registryHive, location uninstallLocation) ([]Software, error) {
+	k, err := hive.OpenKey(location.Root, location.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	subkeys, err := k.SubKeyNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var software []Software
+	for _, subkey := range subkeys {
+		sk, err := k.OpenSubKey(subkey)
+		if err != nil {
+			continue
+		}
+		item, ok := readUninstallEntry(sk, subkey, location.Scope)
+		sk.Close()
+		if ok {
+			software = append(software, item)
+		}
+	}
+	return software, nil
+}
+
+// readUninstallEntry reads one Uninstall subkey. Entries without a DisplayName and
+// those marked SystemComponent=1 are hidden from Programs and Features, so they are skipped too.
+func readUninstallEntry(sk registryKey, subkey, scope string) (Software, bool) {
+	displayName, err := sk.StringValue("DisplayName")
+	if err != nil || displayName == "" {
+		return Software{}, false
+	}
+	if systemComponent, err := sk.IntegerValue("SystemComponent"); err == nil && systemComponent == 1 {
+		return Software{}, false
+	}
+
+	softwareItem := Software{
+		Name:        displayName,
+		ID:          subkey,
+		Source:      "registry",
+		RegistryKey: subkey,
+		Scope:       scope,
+	}
+
+	// Get optional fields
+	optional := map[string]*string{
+		"DisplayVersion":       &softwareItem.Version,
+		"Publisher":            &softwareItem.Publisher,
+		"InstallDate":          &softwareItem.InstallDate,
+		"InstallLocation":      &softwareItem.InstallPath,
+		"UninstallString":      &softwareItem.Uninstall,
+		"QuietUninstallString": &softwareItem.QuietUninstall,
+		"ParentKeyName":        &softwareItem.ParentKeyName,
+	}
+	for name, field := range optional {
+		if value, err := sk.StringValue(name); err == nil {
+			*field = value
+		}
+	}
+	if size, err := sk.IntegerValue("EstimatedSize"); err == nil {
+		softwareItem.EstimatedSize = size
+	}
+
+	return softwareItem, true
+}
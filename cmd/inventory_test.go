@@ -0,0 +1,872 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readInventoryFixture 读取 testdata/inventory 中的样例输出
+func readInventoryFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(testdataDir, "inventory", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseDpkgStatus(t *testing.T) {
+	source := dpkgSource{StatusPath: filepath.Join(testdataDir, "inventory", "dpkg_status")}
+	if !source.Available() {
+		t.Fatal("fixture status file should be available")
+	}
+	got, err := source.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// vim 只剩配置文件，不算已安装
+	want := []Software{
+		{Name: "git", ID: "git", Version: "1:2.43.0-1ubuntu7", Publisher: "Ubuntu Developers <ubuntu-devel-discuss@lists.ubuntu.com>", Source: "dpkg"},
+		{Name: "curl", ID: "curl", Version: "8.5.0-2ubuntu10", Publisher: "Ubuntu Developers <ubuntu-devel-discuss@lists.ubuntu.com>", Source: "dpkg"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParsePackageManagerOutput(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) []Software
+		want  []Software
+	}{
+		{"rpm_qa.txt", parseRpmOutput, []Software{
+			{Name: "bash", ID: "bash", Version: "5.2.26-3.fc40", Publisher: "Fedora Project", InstallDate: "2024-04-22", Source: "rpm"},
+			{Name: "htop", ID: "htop", Version: "3.3.0-3.fc40", InstallDate: "2024-04-24", Source: "rpm"},
+		}},
+		{"flatpak_list.txt", parseFlatpakOutput, []Software{
+			{Name: "GIMP", ID: "org.gimp.GIMP", Version: "2.10.38", Source: "flatpak"},
+			{Name: "com.example.NoName", ID: "com.example.NoName", Version: "1.0", Source: "flatpak"},
+		}},
+		{"snap_list.txt", parseSnapOutput, []Software{
+			{Name: "firefox", ID: "firefox", Version: "125.0.2-1", Publisher: "mozilla", Source: "snap"},
+			{Name: "yq", ID: "yq", Version: "v4.43.1", Publisher: "mikefarah", Source: "snap"},
+		}},
+	}
+	for _, tt := range tests {
+		got := tt.parse(readInventoryFixture(t, tt.name))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWindowsPackageManagers(t *testing.T) {
+	got, err := parseWingetExport(readInventoryFixture(t, "winget_export.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "Git.Git" || got[0].Version != "2.44.0" || got[0].Source != "winget" {
+		t.Fatalf("unexpected winget list %+v", got)
+	}
+
+	got, err = parseChocolateyExport(readInventoryFixture(t, "choco_packages.config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1].Name != "7zip" || got[1].Version != "23.1.0" {
+		t.Fatalf("unexpected choco list %+v", got)
+	}
+
+	got, err = parseScoopExport(readInventoryFixture(t, "scoop_export.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Software{
+		{Name: "ripgrep", ID: "ripgrep", Version: "14.1.0", InstallDate: "2024-03-02", Source: "scoop"},
+		{Name: "jq", ID: "jq", Version: "1.7.1", InstallDate: "2024-01-10", Source: "scoop"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// 旧版本的 scoop export 输出文本
+	got, err = parseScoopExport(readInventoryFixture(t, "scoop_export_legacy.txt"))
+	if err != nil || len(got) != 2 || got[1].Name != "jq" || got[1].Version != "1.7.1" {
+		t.Fatalf("unexpected legacy scoop list %+v, %v", got, err)
+	}
+
+	got, err = parseAppxOutput(readInventoryFixture(t, "appx_list.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Publisher != "Microsoft Corporation" || got[0].InstallPath == "" {
+		t.Fatalf("unexpected AppX list %+v", got)
+	}
+	// 只有一个包时 ConvertTo-Json 输出单个对象
+	got, err = parseAppxOutput([]byte(`{"Name":"Microsoft.WindowsTerminal","Version":"1.19.10821.0"}`))
+	if err != nil || len(got) != 1 {
+		t.Fatalf("single AppX package: %+v, %v", got, err)
+	}
+}
+
+func TestMergePackageEntries(t *testing.T) {
+	software := []Software{
+		{Name: "Git version 2.44.0", ID: "Git_is1", Version: "2.44.0", Publisher: "The Git Development Community", Source: "registry"},
+		{Name: "Mozilla Firefox (x64 en-US)", ID: "Mozilla Firefox 125.0.2 (x64 en-US)", Version: "125.0.2", Source: "registry"},
+		{Name: "Microsoft Visual Studio Code (User)", ID: "{771FD6B0-FA20-440A-A002-3B3BAC16DC50}_is1", Version: "1.88.1", Source: "registry"},
+		{Name: "7-Zip 23.01 (x64)", ID: "7-Zip", Version: "23.01", Source: "registry"},
+		{Name: "Git.Git", ID: "Git.Git", Version: "2.44.0", Source: "winget"},
+		{Name: "Mozilla.Firefox", ID: "Mozilla.Firefox", Version: "125.0.2", Source: "winget"},
+		{Name: "vscode", ID: "vscode", Version: "1.88.1", Source: "chocolatey"},
+		{Name: "7zip", ID: "7zip", Version: "23.1.0", Source: "scoop"},
+		{Name: "git", ID: "git", Version: "2.44.0", Source: "chocolatey"},
+		{Name: "JetBrains.Toolbox", ID: "JetBrains.Toolbox", Version: "2.3", Source: "winget"},
+	}
+
+	got := mergePackageEntries(software, builtinPackageMappings)
+	want := map[string]string{
+		"Git version 2.44.0":                  "winget:git.git",
+		"Mozilla Firefox (x64 en-US)":         "winget:mozilla.firefox",
+		"Microsoft Visual Studio Code (User)": "choco:vscode",
+		"7-Zip 23.01 (x64)":                   "scoop:7zip",
+		"JetBrains.Toolbox":                   "winget:jetbrains.toolbox",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("package entries should be merged: %+v", got)
+	}
+	for _, item := range got {
+		if softwareIdentity(item) != want[item.Name] {
+			t.Fatalf("%s: identity %s, want %s", item.Name, softwareIdentity(item), want[item.Name])
+		}
+	}
+	if got[0].Publisher != "The Git Development Community" || got[3].Version != "23.01" || got[0].RegistryKey != "Git_is1" {
+		t.Fatalf("registry details should be kept: %+v", got)
+	}
+
+	// 合并后仍能按注册表（MSI ProductCode）标识匹配目录条目
+	merged := mergePackageEntries([]Software{
+		{Name: "7-Zip 23.01 (x64)", ID: "{23170F69-40C1-2702-2301-000001000000}", RegistryKey: "{23170F69-40C1-2702-2301-000001000000}", Version: "23.01", Source: "registry"},
+		{Name: "7zip.7zip", ID: "7zip.7zip", Version: "23.01", Source: "winget"},
+	}, builtinPackageMappings)
+	if len(merged) != 1 || softwareIdentity(merged[0]) != "winget:7zip.7zip" {
+		t.Fatalf("7-Zip entries should be merged: %+v", merged)
+	}
+	desired := DesiredSoftware{Name: "7zip", Match: &SoftwareMatch{IDs: []string{"msi:{23170F69-40C1-2702-2301-000001000000}"}}}
+	if match, _, err := findInstalled(desired, merged); err != nil || match == nil {
+		t.Fatalf("msi identity should still match after the merge: %v", err)
+	}
+}
+
+func TestMacOSSources(t *testing.T) {
+	apps := applicationsSource{Dirs: []string{filepath.Join(testdataDir, "inventory", "Applications"), filepath.Join(t.TempDir(), "missing")}}
+	if !apps.Available() {
+		t.Fatal("fixture Applications folder should be available")
+	}
+	got, err := apps.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := map[string]string{}
+	for _, item := range got {
+		versions[item.Name] = item.Version
+	}
+	// 二进制 plist 退回到目录名
+	want := map[string]string{"Broken": "", "Firefox": "125.0.2", "Terminal": "455"}
+	if !reflect.DeepEqual(versions, want) {
+		t.Fatalf("got %v, want %v", versions, want)
+	}
+
+	brew := homebrewSource{Prefixes: []string{filepath.Join(testdataDir, "inventory", "homebrew")}}
+	if !brew.Available() {
+		t.Fatal("fixture Homebrew prefix should be available")
+	}
+	got, err = brew.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "git" || got[0].Version != "2.45.1" || got[0].Source != "homebrew" ||
+		got[1].Name != "iterm2" || got[1].Source != "homebrew-cask" {
+		t.Fatalf("unexpected Homebrew list %+v", got)
+	}
+
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"2.9.0", "2.10.0", -1},
+		{"1.2.3_1", "1.2.3", 1},
+		{"3.5.0", "3.5.0", 0},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+	} {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Fatalf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// fakeInventorySource 返回固定的结果
+type fakeInventorySource struct {
+	name      string
+	available bool
+	software  []Software
+	err       error
+	block     bool // 阻塞直到 ctx 取消
+}
+
+func (s fakeInventorySource) Name() string    { return s.name }
+func (s fakeInventorySource) Available() bool { return s.available }
+func (s fakeInventorySource) Collect(ctx context.Context) ([]Software, error) {
+	if s.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.software, s.err
+}
+
+func TestCollectInventory(t *testing.T) {
+	start := time.Now()
+	got, err := collectInventory(context.Background(), []InventorySource{
+		fakeInventorySource{name: "a", available: true, software: []Software{{Name: "Git"}, {Name: "Security Update for Windows"}}},
+		fakeInventorySource{name: "b", available: true, software: []Software{{Name: "git", Source: "custom"}, {Name: "curl"}}},
+		fakeInventorySource{name: "c", available: true, err: errors.New("boom")},
+		fakeInventorySource{name: "d", software: []Software{{Name: "never"}}},
+		fakeInventorySource{name: "e", available: true, block: true},
+	}, &softwareFilter{}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("one failing source should not fail the inventory: %v", err)
+	}
+	want := []Software{{Name: "Git", Source: "a"}, {Name: "Security Update for Windows", Source: "a"}, {Name: "curl", Source: "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("a hung source should time out")
+	}
+
+	if _, err := collectInventory(context.Background(), []InventorySource{fakeInventorySource{name: "c", available: true, err: errors.New("boom")}}, nil, time.Second); err == nil {
+		t.Fatal("inventory should fail when every source fails")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := collectInventory(ctx, []InventorySource{fakeInventorySource{name: "a", available: true}}, nil, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled inventory should return context.Canceled, got %v", err)
+	}
+}
+
+// fakeRegistryKey 是内存中的注册表键，值为 string 或 uint64
+type fakeRegistryKey struct {
+	values  map[string]interface{}
+	subkeys map[string]*fakeRegistryKey
+}
+
+func (k *fakeRegistryKey) SubKeyNames() ([]string, error) {
+	var names []string
+	for name := range k.subkeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (k *fakeRegistryKey) OpenSubKey(name string) (registryKey, error) {
+	if sk, ok := k.subkeys[name]; ok {
+		return sk, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+func (k *fakeRegistryKey) StringValue(name string) (string, error) {
+	if value, ok := k.values[name].(string); ok {
+		return value, nil
+	}
+	return "", errRegistryValueNotFound
+}
+
+func (k *fakeRegistryKey) IntegerValue(name string) (uint64, error) {
+	if value, ok := k.values[name].(uint64); ok {
+		return value, nil
+	}
+	return 0, errRegistryValueNotFound
+}
+
+func (k *fakeRegistryKey) Close() error { return nil }
+
+// fakeRegistryHive 按 "HKLM\路径" 查找键
+type fakeRegistryHive map[string]*fakeRegistryKey
+
+func (h fakeRegistryHive) OpenKey(root, path string) (registryKey, error) {
+	if k, ok := h[root+`\`+path]; ok {
+		return k, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+func TestRegistrySourceFakeHive(t *testing.T) {
+	uninstall := `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+	hive := fakeRegistryHive{
+		`HKLM\` + uninstall: {subkeys: map[string]*fakeRegistryKey{
+			"{23170F69-40C1-2702-2301-000001000000}": {values: map[string]interface{}{
+				"DisplayName":          "7-Zip 23.01 (x64 edition)",
+				"DisplayVersion":       "23.01.00.0",
+				"Publisher":            "Igor Pavlov",
+				"EstimatedSize":        uint64(5731),
+				"QuietUninstallString": `MsiExec.exe /X{23170F69-40C1-2702-2301-000001000000} /qn`,
+			}},
+			"KB5034441": {values: map[string]interface{}{
+				"DisplayName":   "Update for 7-Zip",
+				"ParentKeyName": "{23170F69-40C1-2702-2301-000001000000}",
+			}},
+			"Hidden": {values: map[string]interface{}{
+				"DisplayName":     "Windows Hidden Component",
+				"SystemComponent": uint64(1),
+			}},
+			"NoName": {values: map[string]interface{}{"DisplayVersion": "1.0"}},
+		}},
+		// 没有 WOW6432Node 时跳过该位置
+		`HKCU\` + uninstall: {subkeys: map[string]*fakeRegistryKey{
+			"{771FD6B0-FA20-440A-A002-3B3BAC16DC50}_is1": {values: map[string]interface{}{
+				"DisplayName":     "Microsoft Visual Studio Code (User)",
+				"DisplayVersion":  "1.88.1",
+				"InstallLocation": `C:\Users\me\AppData\Local\Programs\Microsoft VS Code\`,
+			}},
+		}},
+	}
+
+	got, err := registrySource{Hive: hive}.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := map[string]Software{}
+	for _, item := range got {
+		byKey[item.RegistryKey] = item
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", got)
+	}
+
+	zip := byKey["{23170F69-40C1-2702-2301-000001000000}"]
+	if zip.Scope != "machine" || zip.EstimatedSize != 5731 || zip.QuietUninstall == "" || zip.Publisher != "Igor Pavlov" {
+		t.Fatalf("unexpected machine entry %+v", zip)
+	}
+	if update := byKey["KB5034441"]; update.ParentKeyName != "{23170F69-40C1-2702-2301-000001000000}" {
+		t.Fatalf("ParentKeyName not captured: %+v", update)
+	}
+	if code := byKey["{771FD6B0-FA20-440A-A002-3B3BAC16DC50}_is1"]; code.Scope != "user" || code.InstallPath == "" {
+		t.Fatalf("unexpected user entry %+v", code)
+	}
+
+	if _, err := (registrySource{Hive: fakeRegistryHive{}}).Collect(context.Background()); err == nil {
+		t.Fatal("collect should fail when no Uninstall key exists")
+	}
+}
+
+func TestSoftwareFilterRules(t *testing.T) {
+	filter, errs := newSoftwareFilter([]string{
+		"Steam",
+		"Mozilla*",
+		"regex:^Epic Games (Launcher|Store)$",
+		"publisher:Valve*",
+		"!Java Runtime Tools",
+		"regex:([",
+		"glob:",
+	})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 invalid patterns, got %v", errs)
+	}
+
+	tests := []struct {
+		item Software
+		skip bool
+		rule string
+	}{
+		{Software{Name: "Steam Link"}, true, "Steam"},
+		{Software{Name: "Mozilla Firefox (x64 en-US)"}, true, "Mozilla*"},
+		{Software{Name: "Thunderbird by Mozilla"}, false, ""},
+		{Software{Name: "epic games launcher"}, true, "regex:^Epic Games (Launcher|Store)$"},
+		{Software{Name: "Half-Life", Publisher: "Valve Corporation"}, true, "publisher:Valve*"},
+		// 名称中包含 KB 或 Runtime 的正常软件不再被误删
+		{Software{Name: "KBase Explorer"}, false, ""},
+		{Software{Name: "Azul Zulu Runtime Manager"}, false, ""},
+		{Software{Name: "Update for Windows 10 (KB5034441)"}, true, "Update for"},
+		{Software{Name: "2024-01 Cumulative Update KB5034441"}, true, `regex:\bKB\d{6,7}\b`},
+		{Software{Name: "Microsoft Visual C++ 2015-2022 Redistributable (x64)"}, true, "glob:Microsoft Visual C++ *"},
+		{Software{Name: "Microsoft Windows Desktop Runtime - 8.0.4 (x64)"}, true, `regex:^Microsoft (Windows Desktop|ASP\.NET Core|Edge WebView2) Runtime\b`},
+		{Software{Name: "Go"}, true, `regex:^[[:ascii:]]{0,2}$`},
+		{Software{Name: "微信"}, false, ""},
+		// ! 规则优先于内置规则
+		{Software{Name: "Java Runtime Tools Redistributable"}, false, "!Java Runtime Tools"},
+	}
+	for _, tt := range tests {
+		decision := filter.Explain(tt.item)
+		rule := ""
+		if decision.Rule != nil {
+			rule = decision.Rule.Pattern
+		}
+		if decision.Skip != tt.skip || rule != tt.rule {
+			t.Errorf("%q: skip=%v rule=%q, want skip=%v rule=%q", tt.item.Name, decision.Skip, rule, tt.skip, tt.rule)
+		}
+	}
+
+	if invalid := invalidSkipPatterns([]string{"ok", "regex:(", "publisher:"}); len(invalid) != 2 {
+		t.Fatalf("unexpected invalid patterns %v", invalid)
+	}
+}
+
+func TestSoftwareExportAndDiff(t *testing.T) {
+	before := []Software{
+		{Name: "Git", Version: "2.44.0", Source: "winget"},
+		{Name: "7-Zip", Version: "23.01", Publisher: "Igor Pavlov"},
+		{Name: "Steam", Version: "2.10"},
+	}
+	after := []Software{
+		{Name: "git", Version: "2.45.1", Source: "winget"},
+		{Name: "7-Zip", Version: "23.01", Publisher: "Igor Pavlov"},
+		{Name: "Python 3.12", Version: "3.12.2"},
+	}
+
+	// 导出为 JSON 和 CSV 后都能重新读取
+	for _, format := range []string{"json", "csv"} {
+		var buf bytes.Buffer
+		if err := writeSoftware(&buf, before, format); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseSoftwareList(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(parsed, before) {
+			t.Fatalf("%s round trip: got %+v", format, parsed)
+		}
+	}
+	if err := writeSoftware(&bytes.Buffer{}, before, "xml"); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+
+	// .orbit 备份中的软件列表
+	orbitPath := filepath.Join(t.TempDir(), "backup.orbit")
+	f, err := os.Create(orbitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create(SoftwareListFileName)
+	writeSoftware(w, before, "json")
+	zw.Close()
+	f.Close()
+
+	fromBackup, err := loadSoftwareSnapshot(context.Background(), orbitPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 加密的备份在内存中解密
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	if err := savePrivateKey(key, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := os.ReadFile(orbitPath)
+	encryptedKey, encryptedData, err := EncryptBackup(plain, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedPath := filepath.Join(t.TempDir(), "encrypted.orbit")
+	if err := WriteEncryptedOrbitFile(encryptedPath, encryptedKey, encryptedData); err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := loadSoftwareSnapshot(context.Background(), encryptedPath, keyPath)
+	if err != nil || !reflect.DeepEqual(decrypted, fromBackup) {
+		t.Fatalf("encrypted backup: %+v, %v", decrypted, err)
+	}
+
+	diff := diffSoftware(fromBackup, after)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "Python 3.12" {
+		t.Fatalf("added: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "Steam" {
+		t.Fatalf("removed: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].From != "2.44.0" || diff.Changed[0].To != "2.45.1" {
+		t.Fatalf("changed: %+v", diff.Changed)
+	}
+	if !diffSoftware(before, before).Empty() {
+		t.Fatal("identical lists should have no differences")
+	}
+
+	// 同一软件在两个列表中的 ID 或来源不同时按名称配对，报告为版本变化
+	noID := []Software{{Name: "Git", Version: "2.44.0"}}
+	registry := []Software{{Name: "Git", Version: "2.45.0", ID: "Git_is1", Source: "registry"}}
+	winget := []Software{{Name: "Git", Version: "2.45.0", ID: "Git.Git", Source: "winget"}}
+	if diff := diffSoftware(noID, registry); len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 1 {
+		t.Fatalf("entry without an ID should pair with the registry entry: %+v", diff)
+	}
+	if diff := diffSoftware(registry, winget); !diff.Empty() {
+		t.Fatalf("registry and winget entries should pair: %+v", diff)
+	}
+
+	query := softwareQuery{Name: "zip", Sources: []string{"winget"}}
+	if query.Match(before[1]) || !(softwareQuery{Publisher: "igor"}).Match(before[1]) {
+		t.Fatal("unexpected query result")
+	}
+}
+
+func TestNormalizeSoftwareName(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla Firefox (x64 en-US)":          "mozilla firefox",
+		"Mozilla Firefox 125.0.2":              "mozilla firefox",
+		"7-Zip 23.01 (x64 edition)":            "7-zip",
+		"Git version 2.44.0":                   "git",
+		"Python 3.12.2 (64-bit)":               "python",
+		"Microsoft Visual Studio Code (User)":  "microsoft visual studio code",
+		"Node.js - 20.11.1":                    "node.js",
+		"VLC media player [x86]":               "vlc media player",
+		"Go Programming Language amd64 go1.22": "go programming language amd64 go1.22",
+		"Notepad++ (64-bit x64)":               "notepad++",
+		"Steam (Valve Corporation)":            "steam (valve corporation)",
+		"  Docker   Desktop  ":                 "docker desktop",
+		"1Password":                            "1password",
+		"2048":                                 "2048",
+	}
+	for input, want := range tests {
+		if got := normalizeSoftwareName(input); got != want {
+			t.Errorf("normalizeSoftwareName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSoftwareIdentityAndMatching(t *testing.T) {
+	identities := map[string]Software{
+		"msi:{23170f69-40c1-2702-2301-000001000000}": {Name: "7-Zip", ID: "{23170F69-40C1-2702-2301-000001000000}", Source: "registry"},
+		"registry:7-zip":             {Name: "7-Zip", ID: "7-Zip", Source: "registry"},
+		"winget:git.git":             {Name: "Git", ID: "Git.Git", Source: "winget"},
+		"dpkg:git":                   {Name: "git", ID: "git", Source: "dpkg"},
+		"bundle:org.mozilla.firefox": {Name: "Firefox", ID: "org.mozilla.firefox", Source: "applications"},
+		"name:mozilla firefox":       {Name: "Mozilla Firefox (x64 en-US)", Source: "registry"},
+	}
+	for want, item := range identities {
+		if got := softwareIdentity(item); got != want {
+			t.Errorf("softwareIdentity(%+v) = %q, want %q", item, got, want)
+		}
+	}
+
+	installed := []Software{
+		{Name: "Mozilla Firefox (x64 en-US)", Publisher: "Mozilla", ID: "Mozilla Firefox 125.0.2 (x64 en-US)", Source: "registry"},
+		{Name: "7-Zip 23.01 (x64)", Publisher: "Igor Pavlov", ID: "{23170F69-40C1-2702-2301-000001000000}", Source: "registry"},
+		{Name: "Microsoft Visual Studio Code (User)", Publisher: "Microsoft Corporation", ID: "{771FD6B0-FA20-440A-A002-3B3BAC16DC50}_is1", Source: "registry"},
+	}
+
+	tests := []struct {
+		desired DesiredSoftware
+		want    string
+	}{
+		{DesiredSoftware{Name: "Mozilla Firefox"}, "Mozilla Firefox (x64 en-US)"},
+		{DesiredSoftware{Name: "7zip", Match: &SoftwareMatch{IDs: []string{"msi:{23170f69-40c1-2702-2301-000001000000}"}}}, "7-Zip 23.01 (x64)"},
+		{DesiredSoftware{Name: "VS Code", Match: &SoftwareMatch{Aliases: []string{"Microsoft Visual Studio Code"}}}, "Microsoft Visual Studio Code (User)"},
+		{DesiredSoftware{Name: "Firefox", Match: &SoftwareMatch{Patterns: []string{`^mozilla firefox\b`}}}, "Mozilla Firefox (x64 en-US)"},
+		// 发布者不符时不按名称匹配
+		{DesiredSoftware{Name: "Mozilla Firefox", Match: &SoftwareMatch{Publisher: "Example"}}, ""},
+		{DesiredSoftware{Name: "Google Chrome"}, ""},
+	}
+	for _, test := range tests {
+		match, reason, err := findInstalled(test.desired, installed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if match != nil {
+			got = match.Name
+		}
+		if got != test.want {
+			t.Errorf("findInstalled(%s) = %q (%s), want %q", test.desired.Name, got, reason, test.want)
+		}
+	}
+
+	if _, _, err := findInstalled(DesiredSoftware{Name: "Bad", Match: &SoftwareMatch{Patterns: []string{"("}}}, installed); err == nil {
+		t.Fatal("invalid pattern should fail")
+	}
+
+	// 同一程序由多个来源报告时只保留一条，不同版本并存时都保留
+	deduped := filterSoftwareList([]Software{
+		{Name: "Git", Version: "2.44.0", ID: "Git_is1", Source: "registry"},
+		{Name: "Git", Version: "2.44.0", ID: "Git.Git", Source: "winget"},
+		{Name: "Python 3.11.8 (64-bit)", Version: "3.11.8", ID: "{A}", Source: "registry"},
+		{Name: "Python 3.12.2 (64-bit)", Version: "3.12.2", ID: "{B}", Source: "registry"},
+		{Name: "Python 3.12.2 (64-bit)", Version: "3.12.2", ID: "{B}", Source: "registry"},
+	})
+	if len(deduped) != 3 {
+		t.Fatalf("unexpected deduplicated list: %+v", deduped)
+	}
+}
+
+func TestBuildInstallPlan(t *testing.T) {
+	backedUp := []Software{
+		{Name: "Mozilla Firefox (x64 en-US)", Version: "125.0.2", Source: "registry"},
+		{Name: "Git", Version: "2.44.0", ID: "Git.Git", Source: "winget"},
+		{Name: "Git version 2.44.0", Version: "2.44.0", Source: "registry"},
+		{Name: "7-Zip 23.01 (x64)", Version: "23.01", Source: "registry"},
+		{Name: "Steam", Version: "2.10", Source: "registry"},
+		{Name: "Some Internal Tool", Version: "1.0", Source: "registry"},
+	}
+	installed := []Software{
+		{Name: "7-Zip 24.08 (x64)", Version: "24.08", Source: "registry"},
+	}
+	catalog := &SoftwareCatalog{Software: []DesiredSoftware{
+		{Name: "Mozilla Firefox"},
+		{Name: "Git for Windows", Match: &SoftwareMatch{IDs: []string{"winget:Git.Git"}, Aliases: []string{"Git"}}},
+		{Name: "Steam"},
+	}}
+	blacklist := &SoftwareBlacklist{Software: []string{"steam"}}
+
+	plan, err := buildInstallPlan(backedUp, installed, catalog, blacklist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var install []string
+	for _, item := range plan.Install {
+		install = append(install, item.Catalog.Name)
+	}
+	// 两个 Git 条目对应同一个目录条目，只安装一次
+	if !reflect.DeepEqual(install, []string{"Mozilla Firefox", "Git for Windows"}) {
+		t.Fatalf("install: %v", install)
+	}
+	if plan.Install[1].Reason != "id winget:git.git" {
+		t.Fatalf("unexpected reason %q", plan.Install[1].Reason)
+	}
+	if len(plan.Installed) != 1 || plan.Installed[0].Name != "7-Zip 23.01 (x64)" {
+		t.Fatalf("installed: %+v", plan.Installed)
+	}
+	if len(plan.Blacklisted) != 1 || plan.Blacklisted[0].Name != "Steam" {
+		t.Fatalf("blacklisted: %+v", plan.Blacklisted)
+	}
+	if len(plan.NotInCatalog) != 1 || plan.NotInCatalog[0].Name != "Some Internal Tool" {
+		t.Fatalf("not in catalog: %+v", plan.NotInCatalog)
+	}
+
+	var buf bytes.Buffer
+	if err := writeInstallPlan(&buf, plan, "table"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Git 2.44.0 (winget) -> Git for Windows") {
+		t.Fatalf("unexpected table output:\n%s", buf.String())
+	}
+}
+
+func TestPlanIgnoresExcludedPatterns(t *testing.T) {
+	firefox := Software{Name: "Mozilla Firefox (x64 en-US)", Version: "125.0.2", Source: "registry"}
+
+	// 默认配置的 excluded_patterns 包含 Firefox，所以它不会出现在过滤后的清单中
+	filter, errs := newSoftwareFilter([]string{"Mozilla Firefox", "Visual Studio Code", "Google Chrome"})
+	if len(errs) > 0 || !filter.Skip(firefox) {
+		t.Fatalf("default patterns should skip %s: %v", firefox.Name, errs)
+	}
+
+	catalog := &SoftwareCatalog{Software: []DesiredSoftware{{Name: "Mozilla Firefox"}}}
+	sources := []InventorySource{fakeInventorySource{name: "registry", available: true, software: []Software{firefox}}}
+	plan, err := planFromSoftware(context.Background(), []Software{firefox}, sources, catalog, &SoftwareBlacklist{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Install) != 0 || len(plan.Installed) != 1 {
+		t.Fatalf("excluded but installed software should not be planned: %+v", plan)
+	}
+}
+
+func TestWritePackageManifest(t *testing.T) {
+	software := []Software{
+		{Name: "Git version 2.44.0", Version: "2.44.0", ID: "Git_is1", Source: "registry"},
+		{Name: "Mozilla Firefox (x64 en-US)", Version: "125.0.2", Source: "registry"},
+		{Name: "Microsoft.PowerToys", ID: "Microsoft.PowerToys", Source: "winget"},
+		{Name: "jq", ID: "jq", Version: "1.7.1", Source: "homebrew"},
+		{Name: "nodejs", ID: "nodejs", Version: "18.19.0", Source: "dpkg"},
+		{Name: "Internal Tool", Source: "registry"},
+	}
+
+	// 用户映射表中的条目先于内置映射
+	mappingPath := filepath.Join(t.TempDir(), PackageMappingFileName)
+	if err := os.WriteFile(mappingPath, []byte(`{"packages": [{"name": "Internal Tool", "choco": "internal-tool"}, {"name": "Git", "choco": "git.install"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mappings, err := loadPackageMappings(mappingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(format string) (string, []Software) {
+		var buf bytes.Buffer
+		skipped, err := writePackageManifest(&buf, software, format, mappings)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		return buf.String(), skipped
+	}
+	ids := func(list []Software) []string {
+		var result []string
+		for _, item := range list {
+			result = append(result, item.ID)
+		}
+		return result
+	}
+
+	// winget 和 choco 的清单可以被对应的导入解析器读取
+	output, skipped := write(ManifestWinget)
+	parsed, err := parseWingetExport([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(parsed); !reflect.DeepEqual(got, []string{"Git.Git", "Microsoft.PowerToys", "Mozilla.Firefox", "OpenJS.NodeJS"}) {
+		t.Fatalf("winget packages: %v", got)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("winget skipped: %+v", skipped)
+	}
+
+	output, _ = write(ManifestChoco)
+	parsed, err = parseChocolateyExport([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(parsed); !reflect.DeepEqual(got, []string{"firefox", "git.install", "internal-tool", "nodejs"}) {
+		t.Fatalf("choco packages: %v", got)
+	}
+
+	output, _ = write(ManifestBrewfile)
+	if output != "brew \"git\"\nbrew \"jq\"\nbrew \"node\"\ncask \"firefox\"\n" {
+		t.Fatalf("unexpected Brewfile:\n%s", output)
+	}
+
+	output, _ = write(ManifestApt)
+	if output != "firefox\tinstall\ngit\tinstall\nnodejs\tinstall\n" {
+		t.Fatalf("unexpected apt selections:\n%s", output)
+	}
+
+	if _, err := writePackageManifest(&bytes.Buffer{}, software, "pacman", mappings); err == nil {
+		t.Fatal("unknown manifest format should fail")
+	}
+}
+
+func TestSoftwareHistoryTimeline(t *testing.T) {
+	dates := map[string]string{
+		"20240420":                  "2024-04-20",
+		"2024-04-20":                "2024-04-20",
+		"2024/4/20":                 "2024-04-20",
+		"4/20/2024":                 "2024-04-20",
+		"2024-04-20T10:12:31+08:00": "2024-04-20",
+		"yesterday":                 "",
+		"":                          "",
+	}
+	for raw, want := range dates {
+		if got := normalizeInstallDate(raw); got != want {
+			t.Errorf("normalizeInstallDate(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if when, ok := (Software{InstallDate: "2024-04-20"}).InstalledOn(); !ok || when.Day() != 20 {
+		t.Fatalf("unexpected install date %v", when)
+	}
+
+	// 旧版本的软件列表中保存的是注册表的原始日期
+	legacy, err := parseSoftwareList([]byte(`[{"name": "Git", "installDate": "20240420"}]`))
+	if err != nil || legacy[0].InstallDate != "2024-04-20" {
+		t.Fatalf("legacy list: %+v, %v", legacy, err)
+	}
+
+	path := filepath.Join(t.TempDir(), SoftwareHistoryFileName)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	saves := [][]Software{
+		{
+			{Name: "Git", ID: "Git_is1", Version: "2.44.0", InstallDate: "2024-04-20", EstimatedSize: 337 * 1024, Source: "registry", Uninstall: "unins000.exe"},
+			{Name: "Steam", Version: "2.10", Source: "registry"},
+		},
+		// 没有变化，不记录
+		{
+			{Name: "Git", ID: "Git_is1", Version: "2.44.0", InstallDate: "2024-04-20", EstimatedSize: 337 * 1024, Source: "registry"},
+			{Name: "Steam", Version: "2.10", Source: "registry"},
+		},
+		{
+			{Name: "Git", ID: "Git_is1", Version: "2.45.1", InstallDate: "2024-05-10", EstimatedSize: 340 * 1024, Source: "registry"},
+			{Name: "Mozilla Firefox (x64 en-US)", Version: "126.0", InstallDate: "2024-05-12", Source: "registry"},
+		},
+	}
+	for i, software := range saves {
+		written, err := appendSoftwareSnapshot(path, software, start.AddDate(0, 0, 7*i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != (i != 1) {
+			t.Fatalf("save %d: written = %v", i, written)
+		}
+	}
+
+	snapshots, err := readSoftwareHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Software[0].Uninstall != "" {
+		t.Fatalf("unexpected snapshots: %+v", snapshots)
+	}
+
+	// 第一行是完整列表，之后只记录变化
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var record softwareHistoryRecord
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &record) != nil ||
+		record.Software != nil || len(record.Upserted) != 2 || len(record.Removed) != 1 || record.Removed[0].Name != "Steam" {
+		t.Fatalf("expected a full record followed by changes:\n%s", data)
+	}
+
+	var got []string
+	for _, event := range softwareTimeline(snapshots) {
+		got = append(got, fmt.Sprintf("%s %s %s %s", event.Time[:10], event.Kind, event.Name, event.FromVersion+">"+event.Version))
+	}
+	want := []string{
+		"2024-05-01 appeared Git >2.44.0",
+		"2024-05-01 appeared Steam >2.10",
+		"2024-05-15 changed Git 2.44.0>2.45.1",
+		"2024-05-15 appeared Mozilla Firefox (x64 en-US) >126.0",
+		"2024-05-15 disappeared Steam >2.10",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("timeline:\n%s", strings.Join(got, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := writeSoftwareTimeline(&buf, softwareTimeline(snapshots), "table"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "2.44.0 -> 2.45.1") || !strings.Contains(buf.String(), "337.0 MB") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	// 只有 ID 和来源变化（注册表条目合并了 winget 包）时不算消失后重新出现
+	merged := []Software{
+		{Name: "Git", ID: "Git.Git", Version: "2.45.1", InstallDate: "2024-05-10", EstimatedSize: 340 * 1024, Source: "winget", RegistryKey: "Git_is1"},
+		{Name: "Mozilla Firefox (x64 en-US)", Version: "126.0", InstallDate: "2024-05-12", Source: "registry"},
+	}
+	if _, err := appendSoftwareSnapshot(path, merged, start.AddDate(0, 0, 21)); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err = readSoftwareHistory(path); err != nil || len(snapshots) != 3 || len(snapshots[2].Software) != 2 {
+		t.Fatalf("the old Git entry should be replaced: %+v, %v", snapshots, err)
+	}
+	if events := softwareTimeline(snapshots); len(events) != len(want) {
+		t.Fatalf("an ID change should not add events: %+v", events[len(want):])
+	}
+}
@@ -0,0 +1,83 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// inventorySources lists the inventory sources used on Windows. Win32_Product is
+// deliberately not queried: it is slow and triggers MSI self-repair of every product.
+func inventorySources(config SoftwareConfig) []InventorySource {
+	sources := []InventorySource{
+		registrySource{Hive: windowsRegistryHive{}},
+		wingetSource{},
+		chocolateySource{},
+		scoopSource{},
+	}
+	if config.IncludeStoreApps {
+		sources = append(sources, appxSource{})
+	}
+	return sources
+}
+
+// windowsRegistryHive opens keys in the real Windows registry
+type windowsRegistryHive struct{}
+
+func (windowsRegistryHive) OpenKey(root, path string) (registryKey, error) {
+	var base registry.Key
+	switch root {
+	case registryLocalMachine:
+		base = registry.LOCAL_MACHINE
+	case registryCurrentUser:
+		base = registry.CURRENT_USER
+	default:
+		return nil, fmt.Errorf("unknown registry root %s", root)
+	}
+
+	k, err := registry.OpenKey(base, path, registry.ENUMERATE_SUB_KEYS|registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	return windowsRegistryKey{k}, nil
+}
+
+// windowsRegistryKey adapts registry.Key to registryKey
+type windowsRegistryKey struct {
+	key registry.Key
+}
+
+func (k windowsRegistryKey) SubKeyNames() ([]string, error) {
+	return k.key.ReadSubKeyNames(-1)
+}
+
+func (k windowsRegistryKey) OpenSubKey(name string) (registryKey, error) {
+	sk, err := registry.OpenKey(k.key, name, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	return windowsRegistryKey{sk}, nil
+}
+
+func (k windowsRegistryKey) StringValue(name string) (string, error) {
+	value, _, err := k.key.GetStringValue(name)
+	if errors.Is(err, registry.ErrNotExist) {
+		return "", errRegistryValueNotFound
+	}
+	return value, err
+}
+
+func (k windowsRegistryKey) IntegerValue(name string) (uint64, error) {
+	value, _, err := k.key.GetIntegerValue(name)
+	if errors.Is(err, registry.ErrNotExist) {
+		return 0, errRegistryValueNotFound
+	}
+	return value, err
+}
+
+func (k windowsRegistryKey) Close() error {
+	return k.key.Close()
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// decodePowerShellList decodes the output of ConvertTo-Json, which prints a single
+// object instead of an array when there is only one result
+func decodePowerShellList(output []byte, v interface{}) error {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		output = []byte("[]")
+	} else if output[0] != '[' {
+		output = append(append([]byte("["), output...), ']')
+	}
+	return json.Unmarshal(output, v)
+}
+
+// appxScript lists MSIX/AppX packages installed for the current user, leaving out
+// frameworks and packages that ship with Windows
+const appxScript = `Get-AppxPackage | Where-Object { -not $_.IsFramework -and $_.SignatureKind -ne 'System' } | ` +
+	`Select-Object Name, Version, Publisher, InstallLocation | ConvertTo-Json -Compress`
+
+// appxSource lists Microsoft Store (MSIX/AppX) apps; enabled by software.include_store_apps
+type appxSource struct{}
+
+func (appxSource) Name() string { return "appx" }
+
+func (appxSource) Available() bool { return commandAvailable("powershell") }
+
+func (appxSource) Collect(ctx context.Context) ([]Software, error) {
+	output, err := runInventoryCommand(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", appxScript)
+	if err != nil {
+		return nil, err
+	}
+	return parseAppxOutput(output)
+}
+
+// appxPackage is one package as printed by appxScript
+type appxPackage struct {
+	Name            string `json:"Name"`
+	Version         string `json:"Version"`
+	Publisher       string `json:"Publisher"`
+	InstallLocation string `json:"InstallLocation"`
+}
+
+// parseAppxOutput parses the JSON printed by appxScript
+func parseAppxOutput(output []byte) ([]Software, error) {
+	var packages []appxPackage
+	if err := decodePowerShellList(output, &packages); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-AppxPackage output: %v", err)
+	}
+
+	var software []Software
+	for _, pkg := range packages {
+		software = append(software, Software{
+			Name:        pkg.Name,
+			ID:          pkg.Name,
+			Version:     pkg.Version,
+			Publisher:   appxPublisherName(pkg.Publisher),
+			InstallPath: pkg.InstallLocation,
+			Source:      "appx",
+		})
+	}
+	return software, nil
+}
+
+// appxPublisherName extracts a readable name from a publisher distinguished name
+// such as "CN=Microsoft Corporation, O=Microsoft Corporation, L=Redmond, C=US"
+func appxPublisherName(dn string) string {
+	attributes := make(map[string]string)
+	for _, part := range strings.Split(dn, ",") {
+		if name, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			attributes[strings.ToUpper(name)] = strings.Trim(value, `"`)
+		}
+	}
+	if attributes["O"] != "" {
+		return attributes["O"]
+	}
+	if attributes["CN"] != "" {
+		return attributes["CN"]
+	}
+	return dn
+}
+
+// exportToTempFile runs a package manager export command that writes its manifest
+// to the path returned by args, and returns the manifest contents
+func exportToTempFile(ctx context.Context, pattern, name string, args func(path string) []string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "orbit-inventory")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, pattern)
+	_, runErr := runInventoryCommand(ctx, name, args(path)...)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		// Some package managers exit non-zero when a package cannot be exported but
+		// still write the manifest; only fail when nothing was written
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, fmt.Errorf("%s did not write an export file", name)
+	}
+	return data, nil
+}
+
+// wingetSource lists packages from winget export, which only reads the local package state
+type wingetSource struct{}
+
+func (wingetSource) Name() string { return "winget" }
+
+func (wingetSource) Available() bool { return commandAvailable("winget") }
+
+func (wingetSource) Collect(ctx context.Context) ([]Software, error) {
+	data, err := exportToTempFile(ctx, "winget.json", "winget", func(path string) []string {
+		return []string{"export", "--output", path, "--include-versions",
+			"--accept-source-agreements", "--disable-interactivity"}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseWingetExport(data)
+}
+
+// wingetExport is the manifest written by winget export
+type wingetExport struct {
+	Sources []struct {
+		Packages []struct {
+			PackageIdentifier string `json:"PackageIdentifier"`
+			Version           string `json:"Version"`
+		} `json:"Packages"`
+	} `json:"Sources"`
+}
+
+// parseWingetExport parses the JSON manifest written by winget export
+func parseWingetExport(data []byte) ([]Software, error) {
+	var export wingetExport
+	// winget writes a UTF-8 byte order mark
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &export); err != nil {
+		return nil, fmt.Errorf("failed to parse winget export: %v", err)
+	}
+
+	var software []Software
+	for _, source := range export.Sources {
+		for _, pkg := range source.Packages {
+			software = append(software, Software{
+				Name:    pkg.PackageIdentifier,
+				ID:      pkg.PackageIdentifier,
+				Version: pkg.Version,
+				Source:  "winget",
+			})
+		}
+	}
+	return software, nil
+}
+
+// chocolateySource lists packages from choco export
+type chocolateySource struct{}
+
+func (chocolateySource) Name() string { return "chocolatey" }
+
+func (chocolateySource) Available() bool { return commandAvailable("choco") }
+
+func (chocolateySource) Collect(ctx context.Context) ([]Software, error) {
+	data, err := exportToTempFile(ctx, "packages.config", "choco", func(path string) []string {
+		return []string{"export", "--output-file-path=" + path, "--include-version-numbers", "--no-progress"}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseChocolateyExport(data)
+}
+
+// parseChocolateyExport parses the packages.config written by choco export
+func parseChocolateyExport(data []byte) ([]Software, error) {
+	var config struct {
+		Packages []struct {
+			ID      string `xml:"id,attr"`
+			Version string `xml:"version,attr"`
+		} `xml:"package"`
+	}
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse choco export: %v", err)
+	}
+
+	var software []Software
+	for _, pkg := range config.Packages {
+		if pkg.ID == "" {
+			continue
+		}
+		software = append(software, Software{
+			Name:    pkg.ID,
+			ID:      pkg.ID,
+			Version: pkg.Version,
+			Source:  "chocolatey",
+		})
+	}
+	return software, nil
+}
+
+// scoopSource lists apps from scoop export
+type scoopSource struct{}
+
+func (scoopSource) Name() string { return "scoop" }
+
+func (scoopSource) Available() bool { return commandAvailable("scoop") }
+
+func (scoopSource) Collect(ctx context.Context) ([]Software, error) {
+	output, err := runInventoryCommand(ctx, "scoop", "export")
+	if err != nil {
+		return nil, err
+	}
+	return parseScoopExport(output)
+}
+
+// parseScoopExport parses scoop export: JSON with an apps list in current versions,
+// "name version [bucket]" lines in versions before 0.3
+func parseScoopExport(output []byte) ([]Software, error) {
+	output = bytes.TrimPrefix(bytes.TrimSpace(output), []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(output, []byte("{")) {
+		var export struct {
+			Apps []struct {
+				Name    string `json:"Name"`
+				Version string `json:"Version"`
+				Updated string `json:"Updated"`
+			} `json:"apps"`
+		}
+		if err := json.Unmarshal(output, &export); err != nil {
+			return nil, fmt.Errorf("failed to parse scoop export: %v", err)
+		}
+
+		var software []Software
+		for _, app := range export.Apps {
+			item := Software{Name: app.Name, ID: app.Name, Version: app.Version, Source: "scoop"}
+			if updated, err := time.Parse(time.RFC3339, app.Updated); err == nil {
+				item.InstallDate = updated.Format("2006-01-02")
+			}
+			software = append(software, item)
+		}
+		return software, nil
+	}
+
+	var software []Software
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		software = append(software, Software{Name: fields[0], ID: fields[0], Version: fields[1], Source: "scoop"})
+	}
+	return software, nil
+}
@@ -343,6 +343,14 @@ func loadFunc(orbitFilePath string) error {
 		logger.Infof("发现 .orbit 文件: %s", orbitFilePath)
 	}
 
+	// 分卷备份先合并
+	resolvedPath, cleanup, err := resolveOrbitFile(orbitFilePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	orbitFilePath = resolvedPath
+
 	// Check if file is encrypted
 	fileData, err := os.ReadFile(orbitFilePath)
 	if err != nil {
@@ -354,6 +362,8 @@ func loadFunc(orbitFilePath string) error {
 		string(fileData[:len(EncryptedVerStr)]) == EncryptedVerStr
 
 	if isEncrypted {
+		// 未指定 --private-key 时使用配置（含 ORBIT_ENCRYPTION_PRIVATE_KEY_PATH）中的私钥
+		privateKeyPath = orbitPrivateKeyPath(privateKeyPath)
 		if privateKeyPath == "" {
 			return fmt.Errorf("检测到加密的orbit文件，但未提供私钥。请使用 --private-key 参数指定私钥文件")
 		}
@@ -387,7 +397,7 @@ var load = &cobra.Command{
 	Short: "Load configuration from an .orbit file",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		logger.Infof("开始启动 load 程序..., 参数为: ", args)
+		logger.Infof("开始启动 load 程序..., 参数为: %v", args)
 
 		if err := loadFunc(args[0]); err != nil {
 			logger.Errorf("load程序执行失败, %v", err)
@@ -400,5 +410,6 @@ var load = &cobra.Command{
 
 func init() {
 	load.Flags().StringVarP(&privateKeyPath, "private-key", "k", "", "Path to private key file for decryption (PEM format)")
+	bindConfigFlag(load, "private-key", "encryption.private_key_path")
 	rootCmd.AddCommand(load)
 }
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// plannedInstall 是计划中可以自动安装的软件
+type plannedInstall struct {
+	Software Software        `json:"software"` // 备份中的条目
+	Catalog  DesiredSoftware `json:"catalog"`  // 用于安装的软件目录条目
+	Reason   string          `json:"reason"`   // 目录条目匹配的依据
+}
+
+// installPlan 比较备份中的软件列表、当前系统和软件目录得到的重装计划
+type installPlan struct {
+	Install      []plannedInstall `json:"install"`      // 可以从软件目录自动安装
+	Installed    []Software       `json:"installed"`    // 当前系统已经安装
+	NotInCatalog []Software       `json:"notInCatalog"` // 软件目录中没有，需要手动安装
+	Blacklisted  []Software       `json:"blacklisted"`  // 在黑名单中，不会安装
+}
+
+// buildInstallPlan 为备份中的每个软件决定处理方式：已安装的跳过，其次检查黑名单，
+// 最后在软件目录中查找。多个备份条目对应同一个目录条目时只安装一次。
+func buildInstallPlan(backedUp, installed []Software, catalog *SoftwareCatalog, blacklist *SoftwareBlacklist) (*installPlan, error) {
+	matchers := make([]*softwareMatcher, len(catalog.Software))
+	for i, desired := range catalog.Software {
+		matcher, err := newSoftwareMatcher(desired)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = matcher
+	}
+
+	plan := &installPlan{
+		Install:      []plannedInstall{},
+		Installed:    []Software{},
+		NotInCatalog: []Software{},
+		Blacklisted:  []Software{},
+	}
+	planned := make(map[int]bool)
+
+	for _, item := range backedUp {
+		if isInstalled(item, installed) {
+			plan.Installed = append(plan.Installed, item)
+			continue
+		}
+
+		entry, reason := -1, ""
+		for i, matcher := range matchers {
+			if ok, why := matcher.Match(item); ok {
+				entry, reason = i, why
+				break
+			}
+		}
+
+		if isBlacklisted(blacklist, item.Name) || (entry >= 0 && isBlacklisted(blacklist, catalog.Software[entry].Name)) {
+			plan.Blacklisted = append(plan.Blacklisted, item)
+			continue
+		}
+		if entry < 0 {
+			plan.NotInCatalog = append(plan.NotInCatalog, item)
+			continue
+		}
+
+		// 目录条目本身可能以其他名称安装在当前系统上
+		if match, _, _ := findInstalled(catalog.Software[entry], installed); match != nil {
+			plan.Installed = append(plan.Installed, item)
+			continue
+		}
+		if planned[entry] {
+			continue
+		}
+		planned[entry] = true
+		plan.Install = append(plan.Install, plannedInstall{Software: item, Catalog: catalog.Software[entry], Reason: reason})
+	}
+	return plan, nil
+}
+
+// isInstalled 判断备份中的条目是否已经安装在当前系统上
+func isInstalled(item Software, installed []Software) bool {
+	for _, inst := range installed {
+		if sameSoftware(item, inst) {
+			return true
+		}
+	}
+	return false
+}
+
+// planSoftwareTitle 格式化计划中的软件名称
+func planSoftwareTitle(item Software) string {
+	title := item.Name
+	if item.Version != "" {
+		title += " " + item.Version
+	}
+	if item.Source != "" {
+		title += " (" + item.Source + ")"
+	}
+	return title
+}
+
+// writeInstallPlan 以 table 或 json 格式输出计划
+func writeInstallPlan(w io.Writer, plan *installPlan, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	case "table", "":
+		fmt.Fprintf(w, "可以自动安装 (%d):\n", len(plan.Install))
+		for _, install := range plan.Install {
+			fmt.Fprintf(w, "  %s -> %s [%s]\n", planSoftwareTitle(install.Software), install.Catalog.Name, install.Reason)
+		}
+		sections := []struct {
+			title string
+			items []Software
+		}{
+			{"不在软件目录中，需要手动安装", plan.NotInCatalog},
+			{"在黑名单中", plan.Blacklisted},
+			{"已安装", plan.Installed},
+		}
+		for _, section := range sections {
+			fmt.Fprintf(w, "%s (%d):\n", section.title, len(section.items))
+			for _, item := range section.items {
+				fmt.Fprintf(w, "  %s\n", planSoftwareTitle(item))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的输出格式: %s (可用 table、json)", format)
+}
+
+// planFromBackup 读取备份中的软件列表，与当前系统和软件目录比较得到计划
+func planFromBackup(ctx context.Context, backupPath, privateKeyPath string) (*installPlan, error) {
+	if !strings.HasSuffix(strings.ToLower(backupPath), ".orbit") {
+		return nil, fmt.Errorf("需要 .orbit 备份文件: %s", backupPath)
+	}
+	backedUp, err := readOrbitSoftwareList(backupPath, privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份中的软件列表失败: %w", err)
+	}
+	catalog, err := loadSoftwareCatalog()
+	if err != nil {
+		return nil, fmt.Errorf("加载软件目录失败: %w", err)
+	}
+	blacklist, err := loadBlacklist()
+	if err != nil {
+		return nil, fmt.Errorf("加载黑名单失败: %w", err)
+	}
+	return planFromSoftware(ctx, backedUp, inventorySources(currentSoftwareConfig()), catalog, blacklist)
+}
+
+// planFromSoftware 与 sources 报告的已安装软件比较得到计划。这里不使用 excluded_patterns：
+// 被排除的软件只是不备份，仍然算作已安装，不能再次安装。
+func planFromSoftware(ctx context.Context, backedUp []Software, sources []InventorySource, catalog *SoftwareCatalog, blacklist *SoftwareBlacklist) (*installPlan, error) {
+	installed, err := collectInventory(ctx, sources, nil, InventorySourceTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("获取已安装软件失败: %w", err)
+	}
+	return buildInstallPlan(backedUp, installed, catalog, blacklist)
+}
+
+// installFromBackup 按备份生成的计划安装软件
+func installFromBackup(ctx context.Context, backupPath, privateKeyPath string) error {
+	plan, err := planFromBackup(ctx, backupPath, privateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("备份中的软件: %d 个可以自动安装，%d 个不在软件目录中，%d 个在黑名单中，%d 个已安装",
+		len(plan.Install), len(plan.NotInCatalog), len(plan.Blacklisted), len(plan.Installed))
+	for _, item := range plan.NotInCatalog {
+		logger.Warnf("需要手动安装: %s", planSoftwareTitle(item))
+	}
+
+	failed := 0
+	for _, install := range plan.Install {
+		logger.Infof("Installing: %s", install.Catalog.Name)
+		if err := downloadAndInstall(install.Catalog); err != nil {
+			logger.Errorf("Failed to install %s: %v", install.Catalog.Name, err)
+			failed++
+		} else {
+			logger.Infof("Successfully installed %s", install.Catalog.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个软件安装失败", failed)
+	}
+	return nil
+}
+
+// plan 命令的参数
+var (
+	planFormat     string
+	planPrivateKey string // 解密加密备份的私钥
+)
+
+// planCmd 根据备份中的软件列表生成重装计划
+var planCmd = &cobra.Command{
+	Use:   "plan <backup.orbit>",
+	Short: "Show which apps from a backup can be reinstalled on this machine",
+	Long: `Compare the software list stored in a backup with the software installed on
+this machine and the software catalog, and report for every app that is not
+installed yet whether it:
+  - can be installed automatically from the catalog
+  - is missing from the catalog and has to be installed by hand
+  - is blacklisted and will not be installed
+
+Catalog entries are matched by name, ignoring architecture, locale and version
+suffixes, and by the aliases, IDs and patterns in their "match" rules.
+Run 'orbit install --from <backup.orbit>' to carry out the plan.
+
+Encrypted backups are decrypted in memory with --private-key, or with
+encryption.private_key_path from the configuration.
+
+Examples:
+  orbit plan backup.orbit
+  orbit plan backup.orbit --format json
+  orbit plan backup.orbit --private-key ./keys/private.pem`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, err := planFromBackup(cmd.Context(), args[0], planPrivateKey)
+		if err != nil {
+			logger.Errorf("生成计划失败: %v", err)
+			os.Exit(1)
+		}
+		if err := writeInstallPlan(os.Stdout, plan, planFormat); err != nil {
+			logger.Errorf("输出计划失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringVar(&planFormat, "format", "table", "Output format: table or json")
+	planCmd.Flags().StringVar(&planPrivateKey, "private-key", "", "Private key for encrypted backups (default: encryption.private_key_path)")
+}
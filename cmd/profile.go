@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/spf13/cobra"
+)
+
+// 创建 profile 时继承的基础 profile
+var profileBase string
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// profileCmd profile 管理主命令
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage configuration profiles",
+	Long: `Manage named configuration profiles stored in info.json.
+
+Each profile only stores the values that differ from its base profile and
+inherits everything else. Use the global --profile flag to run any command
+with a specific profile without changing the active one.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a new profile",
+	Long: `Create a new profile that inherits from the default profile or the one given by --from.
+
+Examples:
+  orbit profile create work
+  orbit profile create work-laptop --from work
+  orbit --profile work config set backup-path "D:\work-backups"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := createProfile(GetConfigManager(), args[0], profileBase); err != nil {
+			logger.Errorf("创建 profile 失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("已创建 profile %s (继承自 %s)", args[0], profileBaseOrDefault(profileBase))
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Long:  `Show all profiles. The active profile is marked with *.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configManager := requireConfigManager()
+
+		root := configManager.GetRootConfig()
+		active := selectedProfile(root, "")
+		for _, name := range profileNames(root) {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			if name == DefaultProfileName {
+				logger.Infof("%s %s", marker, name)
+				continue
+			}
+			profile := root.Profiles[name]
+			logger.Infof("%s %s (继承自 %s, %d 项覆盖)", marker, name, profile.parentName(), countValues(profile.Values))
+		}
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Switch the active profile",
+	Long:  `Set the profile used by all commands when --profile is not given.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := useProfile(GetConfigManager(), args[0]); err != nil {
+			logger.Errorf("切换 profile 失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("当前 profile: %s", args[0])
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a profile",
+	Long:  `Delete a profile. The default profile and profiles inherited by others cannot be deleted.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := deleteProfile(GetConfigManager(), args[0]); err != nil {
+			logger.Errorf("删除 profile 失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("已删除 profile %s", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileCreateCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileDeleteCmd)
+
+	profileCreateCmd.Flags().StringVar(&profileBase, "from", "", "Base profile to inherit from (default: default)")
+}
+
+// profileBaseOrDefault 返回创建 profile 时实际使用的基础 profile
+func profileBaseOrDefault(base string) string {
+	if base == "" {
+		return DefaultProfileName
+	}
+	return base
+}
+
+// countValues 统计 profile 中覆盖的叶子值数量
+func countValues(values map[string]interface{}) int {
+	count := 0
+	for _, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			count += countValues(nested)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// createProfile 创建继承自 base 的空 profile
+func createProfile(configManager *ConfigManager, name, base string) error {
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		return fmt.Errorf("配置管理器未初始化")
+	}
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("无效的 profile 名称: %s", name)
+	}
+
+	return configManager.UpdateRootConfig(func(root *UserConfig) error {
+		if name == DefaultProfileName {
+			return fmt.Errorf("profile %s 已存在", name)
+		}
+		if _, ok := root.Profiles[name]; ok {
+			return fmt.Errorf("profile %s 已存在", name)
+		}
+		if _, err := profileChain(root, profileBaseOrDefault(base)); err != nil {
+			return fmt.Errorf("基础 profile 无效: %w", err)
+		}
+
+		if root.Profiles == nil {
+			root.Profiles = make(map[string]ConfigProfile)
+		}
+		profile := ConfigProfile{}
+		if base != DefaultProfileName {
+			profile.Inherits = base
+		}
+		root.Profiles[name] = profile
+		return nil
+	})
+}
+
+// useProfile 把 name 设为 active_profile
+func useProfile(configManager *ConfigManager, name string) error {
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		return fmt.Errorf("配置管理器未初始化")
+	}
+
+	return configManager.UpdateRootConfig(func(root *UserConfig) error {
+		if _, err := profileChain(root, name); err != nil {
+			return err
+		}
+		root.ActiveProfile = name
+		return nil
+	})
+}
+
+// deleteProfile 删除 profile；被删除的是当前 profile 时切换回 default
+func deleteProfile(configManager *ConfigManager, name string) error {
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		return fmt.Errorf("配置管理器未初始化")
+	}
+	if name == DefaultProfileName {
+		return fmt.Errorf("不能删除 %s profile", DefaultProfileName)
+	}
+
+	// 通过 --profile 使用被删除的 profile 时，同样回到 active_profile
+	override := configManager.profile
+	if override == name {
+		configManager.SetProfile("")
+	}
+
+	err := configManager.UpdateRootConfig(func(root *UserConfig) error {
+		if _, ok := root.Profiles[name]; !ok {
+			return fmt.Errorf("profile %s 不存在", name)
+		}
+		for other, profile := range root.Profiles {
+			if other != name && profile.parentName() == name {
+				return fmt.Errorf("profile %s 继承自 %s，请先删除或修改它", other, name)
+			}
+		}
+
+		delete(root.Profiles, name)
+		if root.ActiveProfile == name {
+			logger.Warnf("已删除当前 profile，切换回 %s", DefaultProfileName)
+			root.ActiveProfile = DefaultProfileName
+		}
+		return nil
+	})
+	if err != nil {
+		configManager.SetProfile(override)
+	}
+	return err
+}
@@ -89,8 +89,16 @@ func readFromOrbitFile(filePath string) error {
 	logger.Infof("最后修改时间: %s ", fileInfo.ModTime().Format("2006-01-02 15:04:05"))
 	logger.Infof("----------------------------------------")
 
+	// 分卷备份先合并
+	resolvedPath, cleanup, err := resolveOrbitFile(filePath)
+	if err != nil {
+		logger.Infof("错误: 无法合并分卷: %v ", err)
+		return err
+	}
+	defer cleanup()
+
 	// 打开zip文件
-	r, err := zip.OpenReader(filePath)
+	r, err := zip.OpenReader(resolvedPath)
 	if err != nil {
 		logger.Infof("错误: 无法打开 .orbit 文件: %v ", err)
 		return err
@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	repairOutputPath     string
+	repairPrivateKeyPath string
+	repairPublicKeyPath  string
+)
+
+const (
+	localFileHeaderSignature = 0x04034b50
+	dataDescriptorSignature  = 0x08074b50
+	localFileHeaderLen       = 30
+	zip64ExtraID             = 0x0001
+)
+
+// salvagedEntry 是通过校验的条目
+type salvagedEntry struct {
+	header zip.FileHeader
+	data   []byte
+}
+
+// repairLoss 记录一个无法恢复的条目
+type repairLoss struct {
+	Name   string
+	Reason string
+}
+
+// repairReport 汇总修复结果
+type repairReport struct {
+	Mode      string
+	Recovered []string
+	Lost      []repairLoss
+}
+
+func (r *repairReport) lose(name, format string, args ...interface{}) {
+	r.Lost = append(r.Lost, repairLoss{Name: name, Reason: fmt.Sprintf(format, args...)})
+}
+
+// salvageZip 从（可能损坏的）zip 数据中找回所有完整的条目。
+// 中央目录可读时按中央目录逐个校验，否则退回到扫描本地文件头。
+func salvageZip(data []byte) ([]salvagedEntry, *repairReport) {
+	if r, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		return salvageFromCentralDirectory(r)
+	} else {
+		logger.Warnf("中央目录不可用 (%v)，改为扫描本地文件头", err)
+	}
+	return salvageFromLocalHeaders(data)
+}
+
+// salvageFromCentralDirectory 逐个读取中央目录中的条目，CRC 校验失败的视为丢失
+func salvageFromCentralDirectory(r *zip.Reader) ([]salvagedEntry, *repairReport) {
+	report := &repairReport{Mode: "central directory"}
+	var entries []salvagedEntry
+
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			report.lose(f.Name, "%v", err)
+			continue
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			report.lose(f.Name, "%v", err)
+			continue
+		}
+
+		entries = append(entries, salvagedEntry{header: f.FileHeader, data: content})
+		report.Recovered = append(report.Recovered, f.Name)
+	}
+
+	return entries, report
+}
+
+// salvageFromLocalHeaders 在数据中搜索本地文件头签名，逐个解析并校验条目。
+// 损坏的条目会被跳过，扫描从下一个签名继续。
+func salvageFromLocalHeaders(data []byte) ([]salvagedEntry, *repairReport) {
+	report := &repairReport{Mode: "local file headers"}
+	var entries []salvagedEntry
+
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, localFileHeaderSignature)
+
+	pos := 0
+	for {
+		idx := bytes.Index(data[pos:], sig)
+		if idx < 0 {
+			break
+		}
+		pos += idx
+
+		entry, next, err := parseLocalEntry(data, pos)
+		if err != nil {
+			if entry != nil {
+				report.lose(entry.header.Name, "%v", err)
+			}
+			pos += 4
+			continue
+		}
+
+		entries = append(entries, *entry)
+		report.Recovered = append(report.Recovered, entry.header.Name)
+		pos = next
+	}
+
+	return entries, report
+}
+
+// parseLocalEntry 解析 pos 处的本地文件头及其数据，返回条目和下一个条目的偏移。
+// 文件名可读但数据损坏时，返回的 entry 非 nil 以便报告丢失的文件名。
+func parseLocalEntry(data []byte, pos int) (*salvagedEntry, int, error) {
+	if len(data)-pos < localFileHeaderLen {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	h := data[pos : pos+localFileHeaderLen]
+
+	flags := binary.LittleEndian.Uint16(h[6:8])
+	method := binary.LittleEndian.Uint16(h[8:10])
+	modTime := binary.LittleEndian.Uint16(h[10:12])
+	modDate := binary.LittleEndian.Uint16(h[12:14])
+	crc := binary.LittleEndian.Uint32(h[14:18])
+	compressedSize := uint64(binary.LittleEndian.Uint32(h[18:22]))
+	uncompressedSize := uint64(binary.LittleEndian.Uint32(h[22:26]))
+	nameLen := int(binary.LittleEndian.Uint16(h[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(h[28:30]))
+
+	dataStart := pos + localFileHeaderLen + nameLen + extraLen
+	if dataStart > len(data) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	name := string(data[pos+localFileHeaderLen : pos+localFileHeaderLen+nameLen])
+	extra := data[pos+localFileHeaderLen+nameLen : dataStart]
+
+	entry := &salvagedEntry{header: zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: msDosTimeToTime(modDate, modTime),
+	}}
+	if name == "" {
+		return nil, 0, fmt.Errorf("空文件名")
+	}
+	if method != zip.Store && method != zip.Deflate {
+		return entry, 0, fmt.Errorf("不支持的压缩方式 %d", method)
+	}
+
+	if compressedSize == 0xFFFFFFFF || uncompressedSize == 0xFFFFFFFF {
+		compressedSize, uncompressedSize = readZip64Sizes(extra, compressedSize, uncompressedSize)
+	}
+
+	if strings.HasSuffix(name, "/") {
+		entry.header.SetMode(os.ModeDir | 0755)
+		return entry, dataStart, nil
+	}
+
+	hasDescriptor := flags&0x8 != 0 && compressedSize == 0
+	var raw []byte
+	next := dataStart
+
+	switch {
+	case !hasDescriptor:
+		end := uint64(dataStart) + compressedSize
+		if end > uint64(len(data)) {
+			return entry, 0, fmt.Errorf("数据被截断")
+		}
+		raw = data[dataStart:end]
+		next = int(end)
+	case method == zip.Deflate:
+		// 大小记录在数据描述符中，借助 deflate 流自身的结束标记确定长度
+		br := bytes.NewReader(data[dataStart:])
+		if _, err := io.Copy(io.Discard, flate.NewReader(br)); err != nil {
+			return entry, 0, fmt.Errorf("解压失败: %v", err)
+		}
+		consumed := len(data[dataStart:]) - br.Len()
+		raw = data[dataStart : dataStart+consumed]
+		next = dataStart + consumed
+	default:
+		// Store + 数据描述符：寻找大小吻合的数据描述符
+		end, ok := findDataDescriptor(data, dataStart)
+		if !ok {
+			return entry, 0, fmt.Errorf("找不到数据描述符")
+		}
+		raw = data[dataStart:end]
+		next = end
+	}
+
+	if hasDescriptor {
+		crc, next = readDataDescriptor(data, next)
+	}
+
+	content := raw
+	if method == zip.Deflate {
+		var err error
+		content, err = io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			return entry, 0, fmt.Errorf("解压失败: %v", err)
+		}
+	}
+	if !hasDescriptor && uint64(len(content)) != uncompressedSize {
+		return entry, 0, fmt.Errorf("大小不匹配")
+	}
+	if crc32.ChecksumIEEE(content) != crc {
+		return entry, 0, zip.ErrChecksum
+	}
+
+	entry.header.SetMode(0644)
+	entry.data = content
+	return entry, next, nil
+}
+
+// readZip64Sizes 从 zip64 扩展字段中读取真实大小
+func readZip64Sizes(extra []byte, compressedSize, uncompressedSize uint64) (uint64, uint64) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		field := extra[4 : 4+size]
+		if id == zip64ExtraID {
+			if uncompressedSize == 0xFFFFFFFF && len(field) >= 8 {
+				uncompressedSize = binary.LittleEndian.Uint64(field[0:8])
+				field = field[8:]
+			}
+			if compressedSize == 0xFFFFFFFF && len(field) >= 8 {
+				compressedSize = binary.LittleEndian.Uint64(field[0:8])
+			}
+			break
+		}
+		extra = extra[4+size:]
+	}
+	return compressedSize, uncompressedSize
+}
+
+// findDataDescriptor 从 start 开始寻找压缩大小与位置吻合的数据描述符，返回数据结束位置
+func findDataDescriptor(data []byte, start int) (int, bool) {
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, dataDescriptorSignature)
+
+	for pos := start; ; pos++ {
+		idx := bytes.Index(data[pos:], sig)
+		if idx < 0 || pos+idx+16 > len(data) {
+			return 0, false
+		}
+		pos += idx
+		if int(binary.LittleEndian.Uint32(data[pos+8:pos+12])) == pos-start {
+			return pos, true
+		}
+	}
+}
+
+// readDataDescriptor 读取数据描述符中的 CRC，签名可有可无
+func readDataDescriptor(data []byte, pos int) (uint32, int) {
+	if pos+4 <= len(data) && binary.LittleEndian.Uint32(data[pos:pos+4]) == dataDescriptorSignature {
+		pos += 4
+	}
+	if pos+12 > len(data) {
+		return 0, len(data)
+	}
+	return binary.LittleEndian.Uint32(data[pos : pos+4]), pos + 12
+}
+
+// msDosTimeToTime 把 MS-DOS 日期和时间转换为 time.Time
+func msDosTimeToTime(dosDate, dosTime uint16) time.Time {
+	return time.Date(
+		int(dosDate>>9+1980),
+		time.Month(dosDate>>5&0xf),
+		int(dosDate&0x1f),
+		int(dosTime>>11),
+		int(dosTime>>5&0x3f),
+		int(dosTime&0x1f*2),
+		0,
+		time.UTC,
+	)
+}
+
+// writeSalvagedZip 把找回的条目写成新的 zip 数据
+func writeSalvagedZip(entries []salvagedEntry) ([]byte, error) {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if seen[entry.header.Name] {
+			continue
+		}
+		seen[entry.header.Name] = true
+
+		header := &zip.FileHeader{
+			Name:     entry.header.Name,
+			Method:   zip.Deflate,
+			Modified: entry.header.Modified,
+		}
+		header.SetMode(entry.header.Mode())
+
+		w, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// repairOrbitFile 修复 src 并把结果写入 dst
+func repairOrbitFile(src, dst string) (*repairReport, error) {
+	resolvedPath, cleanup, err := resolveOrbitFile(src)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	fileData, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取orbit文件失败: %v", err)
+	}
+
+	isEncrypted := len(fileData) >= len(EncryptedVerStr) &&
+		string(fileData[:len(EncryptedVerStr)]) == EncryptedVerStr
+
+	payload := fileData
+	if isEncrypted {
+		if repairPrivateKeyPath == "" {
+			return nil, fmt.Errorf("检测到加密的orbit文件，但未提供私钥。请使用 --private-key 参数指定私钥文件")
+		}
+		privateKey, err := LoadPrivateKey(repairPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("加载私钥失败: %v", err)
+		}
+		encryptedSymmetricKey, encryptedData, err := ReadEncryptedOrbitFile(resolvedPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取加密orbit文件失败: %v", err)
+		}
+		// AES-GCM 是整体认证的，密文任何损坏都会导致解密失败
+		payload, err = DecryptBackup(encryptedSymmetricKey, encryptedData, privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("解密失败，加密数据已损坏，无法修复: %v", err)
+		}
+		logger.Info("备份数据解密成功")
+	}
+
+	entries, report := salvageZip(payload)
+	if len(entries) == 0 {
+		return report, fmt.Errorf("没有找到可恢复的条目")
+	}
+
+	zipData, err := writeSalvagedZip(entries)
+	if err != nil {
+		return report, err
+	}
+
+	if isEncrypted && repairPublicKeyPath != "" {
+		publicKey, err := LoadPublicKey(repairPublicKeyPath)
+		if err != nil {
+			return report, fmt.Errorf("加载公钥失败: %v", err)
+		}
+		encryptedSymmetricKey, encryptedData, err := EncryptBackup(zipData, publicKey)
+		if err != nil {
+			return report, fmt.Errorf("加密备份失败: %v", err)
+		}
+		return report, WriteEncryptedOrbitFile(dst, encryptedSymmetricKey, encryptedData)
+	}
+
+	if isEncrypted {
+		logger.Warnf("未提供公钥，修复后的文件将以未加密形式保存")
+	}
+	return report, os.WriteFile(dst, zipData, 0644)
+}
+
+// printRepairReport 输出修复报告
+func printRepairReport(report *repairReport) {
+	logger.Infof("----------------------------------------")
+	logger.Infof("扫描方式: %s", report.Mode)
+	logger.Infof("已恢复: %d 个条目", len(report.Recovered))
+	for _, name := range report.Recovered {
+		logger.Infof("  [OK]   %s", name)
+	}
+	logger.Infof("已丢失: %d 个条目", len(report.Lost))
+	for _, loss := range report.Lost {
+		logger.Warnf("  [LOST] %s (%s)", loss.Name, loss.Reason)
+	}
+	logger.Infof("----------------------------------------")
+}
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [file.orbit]",
+	Short: "Recover readable entries from a damaged .orbit file",
+	Long: `Scan a damaged .orbit file (or a decrypted payload) and salvage every intact
+entry into a new valid .orbit file.
+
+The central directory is used when it is readable. Otherwise the file is scanned
+for local file headers, so entries can still be recovered from truncated or
+partially overwritten archives.
+
+Examples:
+  orbit repair backup.orbit
+  orbit repair backup.orbit -o fixed.orbit
+  orbit repair backup.orbit -k private.pem --public-key public.pem`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		src := args[0]
+		dst := repairOutputPath
+		if dst == "" {
+			dst = strings.TrimSuffix(findSplitIndexPath(src), ".orbit") + ".repaired.orbit"
+		}
+
+		if repairPrivateKeyPath == "" || repairPublicKeyPath == "" {
+			if configManager := GetConfigManager(); configManager != nil && configManager.IsConfigLoaded() {
+				encryptionConfig := configManager.GetEncryptionConfig()
+				if repairPrivateKeyPath == "" {
+					repairPrivateKeyPath = encryptionConfig.PrivateKeyPath
+				}
+				if repairPublicKeyPath == "" {
+					repairPublicKeyPath = encryptionConfig.PublicKeyPath
+				}
+			}
+		}
+
+		report, err := repairOrbitFile(src, dst)
+		if report != nil {
+			printRepairReport(report)
+		}
+		if err != nil {
+			logger.Errorf("修复失败: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("修复完成，已保存为 %s", dst)
+	},
+}
+
+func init() {
+	repairCmd.Flags().StringVarP(&repairOutputPath, "output", "o", "", "Path of the repaired .orbit file (default <name>.repaired.orbit)")
+	repairCmd.Flags().StringVarP(&repairPrivateKeyPath, "private-key", "k", "", "Path to private key file for decryption (PEM format)")
+	repairCmd.Flags().StringVar(&repairPublicKeyPath, "public-key", "", "Path to public key file used to re-encrypt the repaired file (PEM format)")
+	rootCmd.AddCommand(repairCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+)
+
+// buildTestZip 生成包含若干文件的 zip；raw 为 true 时使用 save 的 CreateRaw 写法，
+// 否则使用带数据描述符的 zip.Writer.Create
+func buildTestZip(t *testing.T, raw bool) ([]byte, map[string][]byte) {
+	t.Helper()
+	files := map[string][]byte{}
+	for i := 0; i < 5; i++ {
+		files[fmt.Sprintf("configs/file%d.json", i)] = bytes.Repeat([]byte(fmt.Sprintf("content %d;", i)), 200)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if raw {
+		set := newEntrySet()
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			set.AddData(name, files[name], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		}
+		if err := writeZipEntries(context.Background(), zw, set.entries, 2); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		for i := 0; i < 5; i++ {
+			name := fmt.Sprintf("configs/file%d.json", i)
+			w, _ := zw.Create(name)
+			w.Write(files[name])
+		}
+	}
+	zw.Close()
+	return buf.Bytes(), files
+}
+
+// verifySalvaged 检查修复后的 zip 能正常读取，且内容与原始一致
+func verifySalvaged(t *testing.T, entries []salvagedEntry, files map[string][]byte) int {
+	t.Helper()
+	data, err := writeSalvagedZip(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("repaired zip is invalid: %v", err)
+	}
+
+	count := 0
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, _ := f.Open()
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(got, files[f.Name]) {
+			t.Fatalf("%s: content mismatch (%v)", f.Name, err)
+		}
+		count++
+	}
+	return count
+}
+
+func TestSalvageBrokenCentralDirectory(t *testing.T) {
+	for _, raw := range []bool{true, false} {
+		data, files := buildTestZip(t, raw)
+
+		// 截掉中央目录
+		truncated := data[:bytes.Index(data, []byte("PK\x01\x02"))-5]
+		entries, report := salvageZip(truncated)
+		if report.Mode != "local file headers" {
+			t.Fatalf("got mode %s", report.Mode)
+		}
+
+		// 最后一个文件的数据被截断，其余文件完整
+		if got := verifySalvaged(t, entries, files); got != 4 {
+			t.Fatalf("raw=%v: recovered %d files, want 4 (lost: %v)", raw, got, report.Lost)
+		}
+	}
+}
+
+func TestSalvageCorruptEntry(t *testing.T) {
+	data, files := buildTestZip(t, true)
+
+	// 破坏第二个文件的压缩数据
+	r, _ := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	offset, _ := r.File[2].DataOffset()
+	corrupt := append([]byte(nil), data...)
+	for i := 0; i < 16; i++ {
+		corrupt[int(offset)+i] ^= 0xFF
+	}
+
+	entries, report := salvageZip(corrupt)
+	if got := verifySalvaged(t, entries, files); got != 4 {
+		t.Fatalf("recovered %d files, want 4", got)
+	}
+	if len(report.Lost) != 1 || report.Lost[0].Name != r.File[2].Name {
+		t.Fatalf("got lost entries %v", report.Lost)
+	}
+}
@@ -23,9 +23,13 @@ This command will:
 - Restore VSCode settings and extensions
 - Update system configuration with restore statistics
 
+With --import-config, the Orbit configuration stored by 'save --include-config'
+is imported as well.
+
 Examples:
   orbit restore backup.orbit
-  orbit restore my_config.orbit`,
+  orbit restore my_config.orbit
+  orbit restore backup.orbit --import-config`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		backupFile := args[0]
@@ -51,8 +55,15 @@ func restoreFromBackup(backupFile string) error {
 		return fmt.Errorf("备份文件不存在: %s", backupFile)
 	}
 
+	// 分卷备份先合并
+	resolvedPath, cleanup, err := resolveOrbitFile(backupFile)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
 	// 打开备份文件
-	r, err := zip.OpenReader(backupFile)
+	r, err := zip.OpenReader(resolvedPath)
 	if err != nil {
 		return fmt.Errorf("无法打开备份文件: %v", err)
 	}
@@ -81,6 +92,11 @@ func restoreFromBackup(backupFile string) error {
 		logger.Warnf("恢复VSCode配置失败: %v", err)
 	}
 
+	// 导入备份中嵌入的 Orbit 配置
+	if err := restoreEmbeddedConfig(tempDir); err != nil {
+		logger.Warnf("导入Orbit配置失败: %v", err)
+	}
+
 	// 读取并显示manifest信息
 	if err := readManifestFromBackup(tempDir); err != nil {
 		logger.Warnf("读取manifest信息失败: %v", err)
@@ -241,5 +257,6 @@ func updateRestoreStats() {
 // 由于我们已经在之前的建议中提到了，这里假设已经添加了这些字段
 
 func init() {
+	restoreCmd.Flags().BoolVar(&restoreImportConfig, "import-config", false, "Also import the Orbit configuration stored in the backup")
 	rootCmd.AddCommand(restoreCmd)
 }
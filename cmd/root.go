@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -36,12 +38,20 @@ type Manifest struct {
 // Software represents an installed software application
 type Software struct {
 	Name        string `json:"name"`
+	ID          string `json:"id,omitempty"` // package ID reported by the source, see softwareIdentity
 	Version     string `json:"version,omitempty"`
 	Publisher   string `json:"publisher,omitempty"`
 	InstallDate string `json:"installDate,omitempty"`
 	InstallPath string `json:"installPath,omitempty"`
 	Uninstall   string `json:"uninstall,omitempty"`
 	Source      string `json:"source,omitempty"`
+
+	// Windows registry details, empty for other sources
+	RegistryKey    string `json:"registryKey,omitempty"`    // Uninstall subkey name, usually a product GUID
+	Scope          string `json:"scope,omitempty"`          // "machine" (HKLM) or "user" (HKCU)
+	ParentKeyName  string `json:"parentKeyName,omitempty"`  // set on updates and add-ons of another product
+	EstimatedSize  uint64 `json:"estimatedSize,omitempty"`  // in KB, as reported by the installer
+	QuietUninstall string `json:"quietUninstall,omitempty"` // QuietUninstallString
 }
 
 // SoftwareList represents the collection of installed software
@@ -61,39 +71,45 @@ type ConfigDirType struct {
 type VSCodeConfig struct {
 	ConfigDirs         []ConfigDirType `json:"config_dirs"`
 	ExcludedExtensions []string        `json:"excluded_extensions"`
-	BackupSetting      bool            `json:"backup_setting"`
+	BackupSetting      bool            `json:"backup_setting" config:"backup-setting"`
 }
 
 // software 配置类
 type SoftwareConfig struct {
 	ExcludedPatterns []string `json:"excluded_patterns"`
-	IncludeStoreApps bool     `json:"include_store_apps"`
-	AutoUpdateList   bool     `json:"auto_update_list"`
+	IncludeStoreApps bool     `json:"include_store_apps" config:"include-store-apps"`
+	AutoUpdateList   bool     `json:"auto_update_list" config:"auto-update-list"`
+	// 软件目录来源（URL 或目录），按优先级排列，见 orbit catalog
+	CatalogSources   []string `json:"catalog_sources,omitempty"`
+	CatalogPublicKey string   `json:"catalog_public_key,omitempty" config:",path"` // 验证目录签名的公钥
 }
 
 // 加密配置类
 type EncryptionConfig struct {
-	Enabled          bool   `json:"enabled"`
-	PublicKeyPath    string `json:"public_key_path"`
-	PrivateKeyPath   string `json:"private_key_path"`
+	Enabled          bool   `json:"enabled" config:"encryption-enabled"`
+	PublicKeyPath    string `json:"public_key_path" config:"public-key-path,path"`
+	PrivateKeyPath   string `json:"private_key_path" config:"private-key-path,path"`
 	DefaultAlgorithm string `json:"default_algorithm"`
 }
 
 // 系统信息类
 type SystemConfig struct {
-	LastBackupTime    string `json:"last_backup_time"`
-	BackupCount       int    `json:"backup_count"`
-	LastRestoreTime   string `json:"last_restore_time,omitempty"`
-	RestoreCount      int    `json:"restore_count,omitempty"`
-	DefaultBackupPath string `json:"default_backup_path"`
+	LastBackupTime    string `json:"last_backup_time" config:",readonly"`
+	BackupCount       int    `json:"backup_count" config:",readonly"`
+	LastRestoreTime   string `json:"last_restore_time,omitempty" config:",readonly"`
+	RestoreCount      int    `json:"restore_count,omitempty" config:",readonly"`
+	DefaultBackupPath string `json:"default_backup_path" config:"backup-path,path"`
 }
 
 type UserConfig struct {
-	System     SystemConfig     `json:"system"`
-	VSCode     VSCodeConfig     `json:"vscode"`
-	Software   SoftwareConfig   `json:"software"`
-	Encryption EncryptionConfig `json:"encryption"`
-	LastUpdate string           `json:"last_update"`
+	SchemaVersion int                      `json:"schema_version"`
+	ActiveProfile string                   `json:"active_profile,omitempty"`
+	System        SystemConfig             `json:"system"`
+	VSCode        VSCodeConfig             `json:"vscode"`
+	Software      SoftwareConfig           `json:"software"`
+	Encryption    EncryptionConfig         `json:"encryption"`
+	Profiles      map[string]ConfigProfile `json:"profiles,omitempty"`
+	LastUpdate    string                   `json:"last_update"`
 }
 
 var rootCmd = &cobra.Command{
@@ -121,21 +137,44 @@ func writeJsonToFile(filePath string, data interface{}) error {
 	return encoder.Encode(data)
 }
 
-func initOrbit_user() {
-	// 使用新的配置管理器初始化配置
+func initOrbit_user(cmd *cobra.Command, args []string) error {
+	// 环境变量和当前命令显式传入的参数覆盖配置文件中的值
+	configOverrides = commandConfigOverrides(cmd)
+
+	// 使用新的配置管理器初始化配置，配置无法加载时不能继续执行（例如会写出未加密的备份）
 	if err := InitGlobalConfigManager(); err != nil {
-		logger.Errorf("初始化配置管理器失败: %v", err)
+		return fmt.Errorf("初始化配置管理器失败: %w", err)
+	}
+
+	// 记录在配置修改日志中
+	if configManager := GetConfigManager(); configManager != nil {
+		configManager.SetCommand(strings.Join(append([]string{cmd.CommandPath()}, args...), " "))
+	}
+	return nil
+}
+
+func init() {
+	// 在解析完命令行参数之后再加载配置，使全局参数对配置加载生效
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := initOrbit_user(cmd, args); err != nil {
+			// 错误由 Execute 记录，不需要打印用法
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return err
+		}
+		return nil
 	}
+
+	rootCmd.PersistentFlags().StringVar(&configFlagPath, "config", "", "Path to the config file or its directory (default: $ORBIT_CONFIG or the platform config dir)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Configuration profile to use (default: the active profile)")
+	rootCmd.PersistentFlags().BoolVar(&forceConfigLoad, "force-config", false, "Load a config file written by a newer Orbit version")
 }
 
 func Execute(log *logrus.Logger) {
 	logger = log
 
-	initOrbit_user()
-
 	if err := rootCmd.Execute(); err != nil {
-
-		logger.Info(err)
+		logger.Error(err)
 		os.Exit(1)
 	}
 }
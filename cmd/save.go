@@ -3,31 +3,81 @@ package cmd
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/fs"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	publicKeyPath string
+	publicKeyPath   string
+	reproducible    bool
+	sourceDateEpoch string
+	splitSize       string
+	includeConfig   bool
 )
 
-// 保存vscode相关配置扩展文件
-func saveVscode(tempDir string) error {
-	logger.Infof("正在保存Vscode配置文件...")
+// 未指定 SOURCE_DATE_EPOCH 时使用 zip 能表示的最早时间 1980-01-01
+const defaultSourceDateEpoch int64 = 315532800
+
+// backupOptions 控制 save 的输出方式
+type backupOptions struct {
+	// Reproducible 为 true 时条目排序、时间归一化，相同输入得到相同字节
+	Reproducible bool
+	// SourceDate 是 Reproducible 模式下写入的所有时间
+	SourceDate time.Time
+	// IncludeConfig 为 true 时把可移植的 Orbit 配置写入备份
+	IncludeConfig bool
+}
+
+// now 返回写入备份的时间，Reproducible 模式下固定为 SourceDate
+func (o backupOptions) now() time.Time {
+	if o.Reproducible {
+		return o.SourceDate
+	}
+	return time.Now()
+}
+
+// newBackupOptions 从命令行参数和 SOURCE_DATE_EPOCH 环境变量构建选项
+func newBackupOptions() (backupOptions, error) {
+	opts := backupOptions{Reproducible: reproducible, IncludeConfig: includeConfig}
+	if !opts.Reproducible {
+		return opts, nil
+	}
+
+	epochStr := sourceDateEpoch
+	if epochStr == "" {
+		epochStr = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+
+	epoch := defaultSourceDateEpoch
+	if epochStr != "" {
+		parsed, err := strconv.ParseInt(epochStr, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("无效的 SOURCE_DATE_EPOCH: %s", epochStr)
+		}
+		epoch = max(parsed, defaultSourceDateEpoch)
+	}
+	opts.SourceDate = time.Unix(epoch, 0).UTC()
+
+	return opts, nil
+}
+
+// vscodeProvider 提供vscode相关配置扩展文件，源文件直接流式写入包内
+type vscodeProvider struct{}
 
-	configs_base := filepath.Join(tempDir, "configs")
-	configs_base_vscode_base := filepath.Join(tempDir, "configs", "vscode_config_dir")
-	configs_base_vscode_base_APPDATA := filepath.Join(tempDir, "configs", "vscode_config_dir", "APPDATA")
-	configs_base_vscode_base_USER := filepath.Join(tempDir, "configs", "vscode_config_dir", "USER")
+func (vscodeProvider) Name() string { return "Vscode配置文件" }
 
+func (vscodeProvider) Collect(ctx context.Context, stage *backupStaging, set *entrySet) error {
 	UsersFile := filepath.Join(CodeConfigDir, "User")
 	WorkspacesFile := filepath.Join(CodeConfigDir, "Workspaces")
 	ConfigsInUser := filepath.Join(CodeUserDir)
@@ -35,80 +85,115 @@ func saveVscode(tempDir string) error {
 		ConfigDirType{"APPDATA", WorkspacesFile, CodeConfigDir},
 		ConfigDirType{"USER", ConfigsInUser, CodeUserDir}}
 
-	err := os.MkdirAll(filepath.Join(configs_base), 0644)
+	for _, dir := range dirs {
+		var archiveDir string
+		switch dir.Name {
+		case "APPDATA":
+			archiveDir = path.Join("configs", "vscode_config_dir", "APPDATA", "Code", filepath.Base(dir.Path))
+		case "USER":
+			archiveDir = path.Join("configs", "vscode_config_dir", "USER", filepath.Base(dir.Path))
+		}
+		logger.Infof("dir.Path: %s -> %s", dir.Path, archiveDir)
+
+		if err := set.AddTree(ctx, archiveDir, dir.Path); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warnf("读取目录 %s 失败: %v", dir.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// manifestProvider 在内存中生成 manifest.json
+type manifestProvider struct {
+	opts backupOptions
+}
+
+func (manifestProvider) Name() string { return "manifest.json" }
+
+func (p manifestProvider) Collect(ctx context.Context, stage *backupStaging, set *entrySet) error {
+	jsonData, err := convertManifestToJson(p.opts.now())
 	if err != nil {
 		return err
 	}
-	err = os.MkdirAll(configs_base_vscode_base, 0644)
+	set.AddData("manifest.json", jsonData, p.opts.now())
+	return nil
+}
+
+// softwareListProvider 扫描已安装软件，生成的 software-list.json 需要暂存
+type softwareListProvider struct {
+	opts backupOptions
+}
+
+func (softwareListProvider) Name() string { return "software-list.json" }
+
+func (p softwareListProvider) Collect(ctx context.Context, stage *backupStaging, set *entrySet) error {
+	dir, err := stage.Dir()
 	if err != nil {
 		return err
 	}
 
-	for _, dir := range dirs {
-		// var workingDir string
-		var extractPath string
-		switch dir.Name {
-		case "APPDATA":
-			workingDir := filepath.Base(dir.Path)
-			logger.Infof("dir.Path: %s", dir.Path)
+	if err := saveSoftwareList(ctx, dir); err != nil {
+		logger.Warnf("保存软件列表失败: %v", err)
+		// Continue with backup even if software list fails
+		return nil
+	}
 
-			extractPath = filepath.Join(configs_base_vscode_base_APPDATA, "Code", workingDir)
-			logger.Infof("extractPath: %s", extractPath)
+	listPath := filepath.Join(dir, "software-list.json")
+	if p.opts.Reproducible {
+		return addStableSoftwareList(set, listPath, p.opts.SourceDate)
+	}
 
-			err = os.MkdirAll(extractPath, 0644)
-			if err != nil {
-				return err
-			}
-		case "USER":
-			workingDir := filepath.Base(dir.Path)
-			logger.Infof("dir.Path: %s", dir.Path)
+	info, err := os.Stat(listPath)
+	if err != nil {
+		return err
+	}
+	set.AddFile("software-list.json", listPath, info)
+	return nil
+}
 
-			extractPath = filepath.Join(configs_base_vscode_base_USER, workingDir)
-			logger.Infof("extractPath: %s", extractPath)
+// addStableSoftwareList 按名称排序软件列表并固定时间戳后再写入包内
+func addStableSoftwareList(set *entrySet, listPath string, sourceDate time.Time) error {
+	data, err := os.ReadFile(listPath)
+	if err != nil {
+		return err
+	}
 
-			err = os.MkdirAll(extractPath, 0644)
-			if err != nil {
-				return err
-			}
-		}
+	var softwareList SoftwareList
+	if err := json.Unmarshal(data, &softwareList); err != nil {
+		return fmt.Errorf("解析软件列表失败: %v", err)
+	}
 
-		if err != nil {
-			return err
+	sort.SliceStable(softwareList.Software, func(i, j int) bool {
+		a, b := softwareList.Software[i], softwareList.Software[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
 		}
+		return a.Version < b.Version
+	})
+	softwareList.Timestamp = sourceDate.Format(time.RFC3339)
 
-		filepath.Walk(dir.Path, func(path string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			relPath, err := filepath.Rel(dir.Path, path) // 获取相对路径
-			if err != nil {
-				return err
-			}
-
-			dstPath := filepath.Join(extractPath, relPath) // 目标路径
-			logger.Infof("正在处理文件: %s.  目录: (%s)", filepath.Base(dstPath), filepath.Dir(dstPath))
-
-			if info.IsDir() {
-				err = os.MkdirAll(dstPath, os.ModePerm) // 创建目录
-				if err != nil {
-					return err
-				}
-			} else {
-				err = copyFile(dstPath, path) // 复制文件
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+	jsonData, err := json.MarshalIndent(softwareList, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化软件列表失败: %v", err)
 	}
-
+	set.AddData("software-list.json", jsonData, sourceDate)
 	return nil
 }
 
+// defaultBackupProviders 返回 save 默认使用的 provider，顺序即包内条目顺序
+func defaultBackupProviders(opts backupOptions) []backupProvider {
+	providers := []backupProvider{vscodeProvider{}, manifestProvider{opts}, softwareListProvider{opts}}
+	if opts.IncludeConfig {
+		providers = append(providers, configBundleProvider{opts})
+	}
+	return providers
+}
+
 // 获取系统信息到manifest 中并转换为 byte array
-func convertManifestToJson() ([]byte, error) {
+func convertManifestToJson(timestamp time.Time) ([]byte, error) {
 	var jsonData []byte // 获取系统信息
 
 	hostname, err := os.Hostname()
@@ -123,7 +208,7 @@ func convertManifestToJson() ([]byte, error) {
 
 	// 创建manifest.json文件
 	var manifestContent Manifest = Manifest{
-		Timestamp: time.Now().Format(time.RFC3339),
+		Timestamp: timestamp.Format(time.RFC3339),
 		OS:        runtime.GOOS,
 		Arch:      runtime.GOARCH,
 		Hostname:  hostname,
@@ -139,52 +224,12 @@ func convertManifestToJson() ([]byte, error) {
 }
 
 // createOrbitZipInMemory creates the orbit zip file in memory and returns the bytes
-func createOrbitZipInMemory(tempDir string) ([]byte, error) {
+func createOrbitZipInMemory(ctx context.Context, entries []zipEntry) ([]byte, error) {
 	var buffer bytes.Buffer
 	zipWriter := zip.NewWriter(&buffer)
 
-	logger.Info("---  正在将文件写入 orbit包")
-	err := filepath.Walk(tempDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relpath, err := filepath.Rel(tempDir, path)
-
-		if relpath == "." {
-			return nil
-		}
-
-		if err != nil {
-			return err
-		}
-
-		// 如果是目录，创建目录条目并返回
-		if info.IsDir() {
-			_, err := zipWriter.Create(relpath + "/")
-			if err != nil {
-				return err
-			}
-			return nil
-		}
-
-		// 如果是文件，创建文件条目并复制内容
-		zipFile, err := zipWriter.Create(relpath)
-		if err != nil {
-			return err
-		}
-
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		_, err = io.Copy(zipFile, file)
-		return err
-	})
-
-	if err != nil {
+	logger.Infof("---  正在将 %d 个条目写入 orbit包 (workers: %d)", len(entries), normalizeWorkers(saveWorkers))
+	if err := writeZipEntries(ctx, zipWriter, entries, saveWorkers); err != nil {
 		return nil, err
 	}
 
@@ -195,39 +240,40 @@ func createOrbitZipInMemory(tempDir string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func createBackup() error {
-	// @param tempDir C:\Users\mmili\AppData\Local\Temp\test_orbit-backup
-	tempDir, err := os.MkdirTemp("", "test_orbit-backup")
-	logger.Infof("成功创建临时目录：%v", tempDir)
+// buildOrbitArchive 运行 provider 收集条目并打包为 zip 数据
+func buildOrbitArchive(ctx context.Context, opts backupOptions, providers []backupProvider) ([]byte, error) {
+	stage := &backupStaging{}
+	defer stage.Cleanup()
+
+	entries, err := collectFromProviders(ctx, stage, providers)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.RemoveAll(tempDir)
 
-	//保存vscode配置文件
-	if err := saveVscode(tempDir); err != nil {
-		return err
+	if opts.Reproducible {
+		logger.Infof("可重现模式: 条目排序，时间统一为 %s", opts.SourceDate.Format(time.RFC3339))
+		normalizeEntries(entries, opts.SourceDate)
 	}
 
-	//获取系统信息写入进manifest.json
-	jsonData, err := convertManifestToJson()
+	// Create zip in memory
+	return createOrbitZipInMemory(ctx, entries)
+}
+
+func createBackup(ctx context.Context) error {
+	opts, err := newBackupOptions()
 	if err != nil {
 		return err
 	}
-	manifestPath := filepath.Join(tempDir, "manifest.json")
-	if err := os.WriteFile(manifestPath, jsonData, 0644); err != nil {
-		return err
-	}
-	logger.Info("创建manifest.json文件")
 
-	//保存已安装软件列表到software-list.json
-	if err := saveSoftwareList(tempDir); err != nil {
-		logger.Warnf("保存软件列表失败: %v", err)
-		// Continue with backup even if software list fails
+	var volumeSize int64
+	if splitSize != "" {
+		if volumeSize, err = parseSize(splitSize); err != nil {
+			return err
+		}
 	}
 
-	// Create zip in memory
-	zipData, err := createOrbitZipInMemory(tempDir)
+	// 收集 vscode 配置、manifest.json 和 software-list.json
+	zipData, err := buildOrbitArchive(ctx, opts, defaultBackupProviders(opts))
 	if err != nil {
 		return err
 	}
@@ -239,7 +285,8 @@ func createBackup() error {
 
 	if configManager != nil && configManager.IsConfigLoaded() {
 		encryptionConfig := configManager.GetEncryptionConfig()
-		useEncryption = encryptionConfig.Enabled
+		// 显式传入 --public-key 时即使配置中关闭了加密也进行加密
+		useEncryption = encryptionConfig.Enabled || publicKeyPath != ""
 		encryptionPublicKeyPath = encryptionConfig.PublicKeyPath
 	} else {
 		// 回退到原来的逻辑
@@ -250,6 +297,9 @@ func createBackup() error {
 	// Handle encryption if enabled
 	if useEncryption && encryptionPublicKeyPath != "" {
 		logger.Infof("使用公钥加密备份文件: %s", encryptionPublicKeyPath)
+		if opts.Reproducible {
+			logger.Warnf("加密使用随机密钥，可重现模式只保证解密后的内容一致")
+		}
 
 		// Load public key
 		publicKey, err := LoadPublicKey(encryptionPublicKeyPath)
@@ -277,6 +327,15 @@ func createBackup() error {
 		logger.Info("备份已成功保存为 backup.orbit")
 	}
 
+	// 按大小切分为 backup.orbit.001, .002 ...，backup.orbit 变为分卷索引
+	if volumeSize > 0 {
+		index, err := splitOrbitFile("backup.orbit", volumeSize)
+		if err != nil {
+			return fmt.Errorf("切分备份失败: %v", err)
+		}
+		logger.Infof("备份已切分为 %d 个分卷，索引文件为 backup.orbit", len(index.Volumes))
+	}
+
 	// 更新系统配置中的备份计数
 	if configManager != nil && configManager.IsConfigLoaded() {
 		err := configManager.UpdateSystemConfig(func(systemConfig *SystemConfig) {
@@ -299,10 +358,26 @@ var save = &cobra.Command{
 	- software-list.json with installed software
 	- configs/ folder with configuration files
 
-Encryption is supported using a user-defined public key.`,
+Encryption is supported using a user-defined public key.
+
+With --reproducible, entries are sorted and all timestamps are taken from
+--source-date-epoch (or the SOURCE_DATE_EPOCH environment variable), so
+identical inputs produce byte-identical unencrypted archives.
+
+With --split SIZE (e.g. 2G, 700M), the backup is written as backup.orbit.001,
+backup.orbit.002, ... and backup.orbit becomes a small index volume. read,
+load and restore reassemble the volumes automatically.
+
+With --include-config, a portable copy of the Orbit configuration is stored
+as orbit-config.json so 'orbit restore --import-config' can set up Orbit on
+a new machine.`,
 	Args: cobra.MaximumNArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := createBackup(); err != nil {
+		// Ctrl+C 时取消正在进行的收集和压缩
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := createBackup(ctx); err != nil {
 			logger.Errorf("---  %v", err)
 			os.Exit(1)
 		}
@@ -310,6 +385,12 @@ Encryption is supported using a user-defined public key.`,
 }
 
 func init() {
+	save.Flags().IntVarP(&saveWorkers, "jobs", "j", saveWorkers, "Number of concurrent workers used to collect and compress files")
+	save.Flags().BoolVar(&reproducible, "reproducible", false, "Produce byte-identical archives for identical inputs")
+	save.Flags().StringVar(&sourceDateEpoch, "source-date-epoch", "", "Unix timestamp used for all entries in --reproducible mode (default $SOURCE_DATE_EPOCH)")
+	save.Flags().StringVar(&splitSize, "split", "", "Split the backup into volumes of at most SIZE (e.g. 2G, 700M)")
+	save.Flags().BoolVar(&includeConfig, "include-config", false, "Store a portable copy of the Orbit configuration in the backup")
 	save.Flags().StringVarP(&publicKeyPath, "public-key", "k", "", "Path to public key file for encryption (PEM format)")
+	bindConfigFlag(save, "public-key", "encryption.public_key_path")
 	rootCmd.AddCommand(save)
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,243 +9,229 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"golang.org/x/sys/windows/registry"
 )
 
-// getInstalledSoftware retrieves installed software from Windows registry and WMI
-func getInstalledSoftware() ([]Software, error) {
-	var softwareList []Software
-
-	// Get software from registry (traditional Windows programs)
-	registrySoftware, err := getSoftwareFromRegistry()
-	if err != nil {
-		logger.Warnf("Failed to get software from registry: %v", err)
-	} else {
-		softwareList = append(softwareList, registrySoftware...)
-	}
-
-	// Get software from WMI (modern Windows Store apps and programs)
-	wmiSoftware, err := getSoftwareFromWMI()
-	if err != nil {
-		logger.Warnf("Failed to get software from WMI: %v", err)
-	} else {
-		softwareList = append(softwareList, wmiSoftware...)
-	}
+// InventorySource is one place installed software can be read from, such as the
+// Windows registry or the dpkg database. Each platform registers its sources in
+// inventorySources (inventory_<os>.go).
+type InventorySource interface {
+	// Name identifies the source and is stored in Software.Source
+	Name() string
+	// Available reports whether the source exists on this machine, e.g. the
+	// package manager is installed
+	Available() bool
+	// Collect lists the software known to the source. It must return when ctx
+	// is cancelled, which happens after InventorySourceTimeout.
+	Collect(ctx context.Context) ([]Software, error)
+}
 
-	// Remove duplicates and filter out system components
-	softwareList = filterSoftwareList(softwareList)
+// InventorySourceTimeout bounds how long a single inventory source may run
+const InventorySourceTimeout = 2 * time.Minute
 
-	logger.Infof("Found %d installed software applications", len(softwareList))
-	return softwareList, err
+// getInstalledSoftware retrieves installed software from every available inventory
+// source, leaving out entries excluded by the configured skip rules
+func getInstalledSoftware(ctx context.Context) ([]Software, error) {
+	return collectInventory(ctx, inventorySources(currentSoftwareConfig()), configuredSoftwareFilter(), InventorySourceTimeout)
 }
 
-// getSoftwareFromRegistry retrieves installed software from Windows registry
-func getSoftwareFromRegistry() ([]Software, error) {
-	var software []Software
+// getAllInstalledSoftware retrieves installed software from every available inventory
+// source without applying the skip rules. Use it to check whether something is
+// installed; the skip rules only decide what is backed up and listed.
+func getAllInstalledSoftware(ctx context.Context) ([]Software, error) {
+	return collectInventory(ctx, inventorySources(currentSoftwareConfig()), nil, InventorySourceTimeout)
+}
 
-	// Registry paths to check for installed software
-	registryPaths := []string{
-		`SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
-		`SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+// currentSoftwareConfig returns the software section of the loaded configuration,
+// or the zero value when no configuration is loaded
+func currentSoftwareConfig() SoftwareConfig {
+	if configManager := GetConfigManager(); configManager != nil {
+		if softwareConfig := configManager.GetSoftwareConfig(); softwareConfig != nil {
+			return *softwareConfig
+		}
 	}
+	return SoftwareConfig{}
+}
 
-	for _, regPath := range registryPaths {
-		k, err := registry.OpenKey(registry.LOCAL_MACHINE, regPath, registry.ENUMERATE_SUB_KEYS|registry.QUERY_VALUE)
-		if err != nil {
+// collectInventory merges the software reported by sources, giving each source at
+// most timeout, and drops entries the filter skips (a nil filter keeps everything).
+// A failing source is logged and skipped; an error is returned only if every
+// available source failed or ctx was cancelled.
+func collectInventory(ctx context.Context, sources []InventorySource, filter *softwareFilter, timeout time.Duration) ([]Software, error) {
+	var softwareList []Software
+	var lastErr error
+	succeeded := 0
+
+	for _, source := range sources {
+		if !source.Available() {
+			logger.Debugf("Inventory source %s is not available", source.Name())
 			continue
 		}
-		defer k.Close()
 
-		subkeys, err := k.ReadSubKeyNames(-1)
+		items, err := collectSource(ctx, source, timeout)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err != nil {
+			logger.Warnf("Failed to get software from %s: %v", source.Name(), err)
+			lastErr = err
 			continue
 		}
+		succeeded++
 
-		for _, subkey := range subkeys {
-			sk, err := registry.OpenKey(k, subkey, registry.QUERY_VALUE)
-			if err != nil {
-				continue
-			}
-
-			displayName, _, err := sk.GetStringValue("DisplayName")
-			if err != nil {
-				sk.Close()
-				continue
-			}
-
-			// Skip system components and updates
-			if shouldSkipSoftware(displayName) {
-				sk.Close()
-				continue
-			}
-
-			softwareItem := Software{
-				Name:   displayName,
-				Source: "registry",
-			}
-
-			// Get optional fields
-			if version, _, err := sk.GetStringValue("DisplayVersion"); err == nil {
-				softwareItem.Version = version
-			}
-
-			if publisher, _, err := sk.GetStringValue("Publisher"); err == nil {
-				softwareItem.Publisher = publisher
-			}
-
-			if installDate, _, err := sk.GetStringValue("InstallDate"); err == nil {
-				softwareItem.InstallDate = installDate
+		for _, item := range items {
+			if item.Source == "" {
+				item.Source = source.Name()
 			}
+			item.InstallDate = normalizeInstallDate(item.InstallDate)
+			softwareList = append(softwareList, item)
+		}
+	}
 
-			if installLocation, _, err := sk.GetStringValue("InstallLocation"); err == nil {
-				softwareItem.InstallPath = installLocation
-			}
+	if succeeded == 0 && lastErr != nil {
+		return nil, lastErr
+	}
 
-			if uninstallString, _, err := sk.GetStringValue("UninstallString"); err == nil {
-				softwareItem.Uninstall = uninstallString
-			}
+	// Attach winget, Chocolatey and Scoop IDs to the registry entries of the same programs
+	softwareList = mergePackageEntries(softwareList, inventoryPackageMappings())
 
-			software = append(software, softwareItem)
-			sk.Close()
+	// Skip system components, updates and configured exclusions
+	kept := softwareList[:0]
+	for _, item := range softwareList {
+		if !filter.Skip(item) {
+			kept = append(kept, item)
 		}
 	}
 
-	return software, nil
-}
+	// Remove duplicates
+	softwareList = filterSoftwareList(kept)
 
-// getSoftwareFromWMI retrieves installed software using Windows Management Instrumentation
-func getSoftwareFromWMI() ([]Software, error) {
-	var software []Software
+	logger.Infof("Found %d installed software applications", len(softwareList))
+	return softwareList, nil
+}
 
-	// Use PowerShell to query WMI for installed software
-	cmd := exec.Command("powershell", "-Command",
-		"Get-WmiObject -Class Win32_Product | Select-Object Name, Version, Vendor, InstallDate | ConvertTo-Json")
+// InstallDateLayout is the format of Software.InstallDate after normalization
+const InstallDateLayout = "2006-01-02"
+
+// installDateLayouts are the date formats reported by inventory sources: the
+// registry uses YYYYMMDD, some installers write a localized date instead
+var installDateLayouts = []string{
+	"20060102",
+	InstallDateLayout,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/1/2",
+	"1/2/2006",
+	"2.1.2006",
+}
 
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to query WMI: %v", err)
+// parseInstallDate parses an install date in any of installDateLayouts
+func parseInstallDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range installDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, fmt.Errorf("unrecognized install date %q", raw)
+}
 
-	// Parse the JSON output from PowerShell
-	var wmiResults []struct {
-		Name        string `json:"Name"`
-		Version     string `json:"Version"`
-		Vendor      string `json:"Vendor"`
-		InstallDate string `json:"InstallDate"`
+// normalizeInstallDate rewrites an install date as InstallDateLayout. Dates that
+// cannot be parsed are dropped rather than stored in an unknown format.
+func normalizeInstallDate(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
 	}
-
-	if err := json.Unmarshal(output, &wmiResults); err != nil {
-		// Try parsing as single object (if only one result)
-		var singleResult struct {
-			Name        string `json:"Name"`
-			Version     string `json:"Version"`
-			Vendor      string `json:"Vendor"`
-			InstallDate string `json:"InstallDate"`
-		}
-		if err := json.Unmarshal(output, &singleResult); err == nil && singleResult.Name != "" {
-			wmiResults = []struct {
-				Name        string `json:"Name"`
-				Version     string `json:"Version"`
-				Vendor      string `json:"Vendor"`
-				InstallDate string `json:"InstallDate"`
-			}{singleResult}
-		} else {
-			return nil, fmt.Errorf("failed to parse WMI output: %v", err)
-		}
+	t, err := parseInstallDate(raw)
+	if err != nil {
+		logger.Debugf("Ignoring install date: %v", err)
+		return ""
 	}
+	return t.Format(InstallDateLayout)
+}
 
-	for _, item := range wmiResults {
-		if shouldSkipSoftware(item.Name) {
-			continue
-		}
+// InstalledOn returns the install date, if the source reported one
+func (s Software) InstalledOn() (time.Time, bool) {
+	t, err := time.Parse(InstallDateLayout, s.InstallDate)
+	return t, err == nil
+}
 
-		softwareItem := Software{
-			Name:        item.Name,
-			Version:     item.Version,
-			Publisher:   item.Vendor,
-			InstallDate: formatWMIDate(item.InstallDate),
-			Source:      "wmi",
-		}
+// collectSource runs one source with a timeout. The result is abandoned if the
+// source does not return in time, so a hung package manager cannot block the scan.
+func collectSource(ctx context.Context, source InventorySource, timeout time.Duration) ([]Software, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-		software = append(software, softwareItem)
+	type result struct {
+		items []Software
+		err   error
 	}
-
-	return software, nil
-}
-
-// shouldSkipSoftware determines if a software should be excluded from the list
-func shouldSkipSoftware(name string) bool {
-	skipPatterns := []string{
-		"Microsoft Visual C++",
-		"Microsoft .NET",
-		"Windows SDK",
-		"Update for",
-		"Security Update",
-		"Hotfix",
-		"Service Pack",
-		"KB",
-		"Runtime",
-		"Redistributable",
-		"System Component",
+	done := make(chan result, 1)
+	go func() {
+		items, err := source.Collect(ctx)
+		done <- result{items, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.items, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out after %v", timeout)
 	}
+}
 
-	nameLower := strings.ToLower(name)
-	for _, pattern := range skipPatterns {
-		if strings.Contains(nameLower, strings.ToLower(pattern)) {
-			return true
-		}
+// runInventoryCommand runs a package manager command and returns its standard output
+func runInventoryCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
-
-	// Skip very short names (likely system components)
-	if len(strings.TrimSpace(name)) < 3 {
-		return true
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v", name, err)
 	}
+	return output, nil
+}
 
-	return false
+// commandAvailable reports whether an executable is on PATH
+func commandAvailable(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
 }
 
-// filterSoftwareList removes duplicates and cleans up the software list
+// filterSoftwareList removes duplicates and cleans up the software list. Two entries
+// are duplicates when they have the same package identity (see softwareIdentity), or
+// the same normalized name and version, which catches one program reported by several
+// sources while keeping side-by-side releases such as Python 3.11 and 3.12.
 func filterSoftwareList(software []Software) []Software {
 	seen := make(map[string]bool)
 	var filtered []Software
 
 	for _, item := range software {
-		// Create a unique key for the software
-		key := strings.ToLower(strings.TrimSpace(item.Name))
-		if key == "" {
+		name := normalizeSoftwareName(item.Name)
+		if name == "" {
 			continue
 		}
 
-		if !seen[key] {
-			seen[key] = true
-			filtered = append(filtered, item)
+		release := "release:" + name + "@" + strings.ToLower(strings.TrimSpace(item.Version))
+		identity := release
+		if strings.TrimSpace(item.ID) != "" {
+			identity = softwareIdentity(item)
 		}
+		if seen[identity] || seen[release] {
+			continue
+		}
+		seen[identity] = true
+		seen[release] = true
+		filtered = append(filtered, item)
 	}
 
 	return filtered
 }
 
-// formatWMIDate converts WMI date format to readable format
-func formatWMIDate(wmiDate string) string {
-	if len(wmiDate) < 8 {
-		return wmiDate
-	}
-
-	// WMI date format: YYYYMMDDHHMMSS.FFFFFF+TZONE
-	dateStr := wmiDate[:8]
-	year := dateStr[:4]
-	month := dateStr[4:6]
-	day := dateStr[6:8]
-
-	return fmt.Sprintf("%s-%s-%s", year, month, day)
-}
-
 // saveSoftwareList creates software-list.json file in the temp directory
-func saveSoftwareList(tempDir string) error {
+func saveSoftwareList(ctx context.Context, tempDir string) error {
 	logger.Info("正在扫描系统已安装的软件...")
 
-	software, err := getInstalledSoftware()
+	software, err := getInstalledSoftware(ctx)
 	if err != nil {
 		return fmt.Errorf("获取已安装软件失败: %v", err)
 	}
@@ -266,5 +253,8 @@ func saveSoftwareList(tempDir string) error {
 	}
 
 	logger.Infof("成功保存 %d 个软件信息到 software-list.json", len(software))
+
+	// Keep a timeline of the inventory for orbit software history
+	recordSoftwareHistory(software)
 	return nil
 }
@@ -0,0 +1,595 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SoftwareListFileName 是 .orbit 备份中软件列表的文件名
+const SoftwareListFileName = "software-list.json"
+
+// liveSoftwareSpec 在 software diff 中表示当前系统
+const liveSoftwareSpec = "live"
+
+// softwareCSVHeader 是 CSV 输出的列
+var softwareCSVHeader = []string{"name", "version", "publisher", "source", "installDate", "installPath", "id"}
+
+// softwareQuery 是 software list / export 的过滤条件
+type softwareQuery struct {
+	Name      string   // 名称包含的子串
+	Publisher string   // 发布者包含的子串
+	Sources   []string // 只保留这些来源
+	All       bool     // 不应用排除规则
+}
+
+// Match 判断条目是否满足过滤条件
+func (q softwareQuery) Match(item Software) bool {
+	if q.Name != "" && !strings.Contains(strings.ToLower(item.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if q.Publisher != "" && !strings.Contains(strings.ToLower(item.Publisher), strings.ToLower(q.Publisher)) {
+		return false
+	}
+	if len(q.Sources) > 0 {
+		for _, source := range q.Sources {
+			if strings.EqualFold(source, item.Source) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// scanSoftware 扫描本机已安装软件并按 query 过滤，结果按名称排序
+func scanSoftware(ctx context.Context, query softwareQuery) ([]Software, error) {
+	filter := configuredSoftwareFilter()
+	if query.All {
+		filter = nil
+	}
+	installed, err := collectInventory(ctx, inventorySources(currentSoftwareConfig()), filter, InventorySourceTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Software
+	for _, item := range installed {
+		if query.Match(item) {
+			result = append(result, item)
+		}
+	}
+	sortSoftware(result)
+	return result, nil
+}
+
+// sortSoftware 按名称（不区分大小写）排序
+func sortSoftware(software []Software) {
+	sort.SliceStable(software, func(i, j int) bool {
+		return strings.ToLower(software[i].Name) < strings.ToLower(software[j].Name)
+	})
+}
+
+// writeSoftware 以 table、json 或 csv 格式输出软件列表
+func writeSoftware(w io.Writer, software []Software, format string) error {
+	switch format {
+	case "json":
+		list := SoftwareList{
+			Timestamp:  time.Now().Format(time.RFC3339),
+			TotalCount: len(software),
+			Software:   software,
+		}
+		if list.Software == nil {
+			list.Software = []Software{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(softwareCSVHeader); err != nil {
+			return err
+		}
+		for _, item := range software {
+			if err := writer.Write([]string{item.Name, item.Version, item.Publisher, item.Source, item.InstallDate, item.InstallPath, item.ID}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tVERSION\tPUBLISHER\tSOURCE")
+		for _, item := range software {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.Name, item.Version, item.Publisher, item.Source)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("不支持的输出格式: %s (可用 table、json、csv)", format)
+}
+
+// parseSoftwareList 解析 software export 的输出：SoftwareList JSON、Software 数组或 CSV
+func parseSoftwareList(data []byte) ([]Software, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case len(trimmed) == 0:
+		return nil, fmt.Errorf("软件列表为空")
+	case trimmed[0] == '{':
+		var list SoftwareList
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("解析软件列表失败: %w", err)
+		}
+		return normalizeSoftwareDates(list.Software), nil
+	case trimmed[0] == '[':
+		var software []Software
+		if err := json.Unmarshal(trimmed, &software); err != nil {
+			return nil, fmt.Errorf("解析软件列表失败: %w", err)
+		}
+		return normalizeSoftwareDates(software), nil
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(trimmed)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 软件列表失败: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV 软件列表缺少 name 列")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var software []Software
+	for _, record := range records[1:] {
+		software = append(software, Software{
+			Name:        field(record, "name"),
+			Version:     field(record, "version"),
+			Publisher:   field(record, "publisher"),
+			Source:      field(record, "source"),
+			InstallDate: field(record, "installDate"),
+			InstallPath: field(record, "installPath"),
+			ID:          field(record, "id"),
+		})
+	}
+	return normalizeSoftwareDates(software), nil
+}
+
+// normalizeSoftwareDates 统一旧版本保存的安装日期格式，例如注册表中的 YYYYMMDD
+func normalizeSoftwareDates(software []Software) []Software {
+	for i := range software {
+		software[i].InstallDate = normalizeInstallDate(software[i].InstallDate)
+	}
+	return software
+}
+
+// readOrbitSoftwareList 读取 .orbit 备份（包括分卷备份和加密的备份）中的 software-list.json，
+// 加密的备份使用 privateKeyPath 解密，为空时使用配置中的私钥
+func readOrbitSoftwareList(orbitFilePath, privateKeyPath string) ([]Software, error) {
+	resolvedPath, cleanup, err := resolveOrbitFile(orbitFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	data, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取 .orbit 文件失败: %v", err)
+	}
+
+	// 加密的备份在内存中解密，不写临时文件
+	if len(data) >= len(EncryptedVerStr) && string(data[:len(EncryptedVerStr)]) == EncryptedVerStr {
+		privateKeyPath = orbitPrivateKeyPath(privateKeyPath)
+		if privateKeyPath == "" {
+			return nil, fmt.Errorf("检测到加密的orbit文件，但未提供私钥。请使用 --private-key 参数指定私钥文件")
+		}
+		privateKey, err := LoadPrivateKey(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("加载私钥失败: %v", err)
+		}
+		encryptedSymmetricKey, encryptedData, err := ReadEncryptedOrbitFile(resolvedPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取加密orbit文件失败: %v", err)
+		}
+		if data, err = DecryptBackup(encryptedSymmetricKey, encryptedData, privateKey); err != nil {
+			return nil, fmt.Errorf("解密备份数据失败: %v", err)
+		}
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("无法打开 .orbit 文件: %v", err)
+	}
+
+	for _, file := range r.File {
+		if file.Name != SoftwareListFileName {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		return parseSoftwareList(data)
+	}
+	return nil, fmt.Errorf("备份中没有 %s", SoftwareListFileName)
+}
+
+// orbitPrivateKeyPath 返回解密备份使用的私钥：优先使用 --private-key，
+// 未指定时使用配置（含 ORBIT_ENCRYPTION_PRIVATE_KEY_PATH）中的私钥
+func orbitPrivateKeyPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if configManager := GetConfigManager(); configManager != nil && configManager.IsConfigLoaded() {
+		return configManager.GetEncryptionConfig().PrivateKeyPath
+	}
+	return ""
+}
+
+// loadSoftwareSnapshot 读取 software diff 的一侧：live 表示扫描当前系统，
+// 以 .orbit 结尾的文件读取备份中的软件列表（加密的备份使用 privateKeyPath 解密），
+// 其他文件按 software export 的输出解析
+func loadSoftwareSnapshot(ctx context.Context, spec, privateKeyPath string) ([]Software, error) {
+	if strings.EqualFold(spec, liveSoftwareSpec) {
+		return scanSoftware(ctx, softwareQuery{})
+	}
+	if strings.HasSuffix(strings.ToLower(spec), ".orbit") {
+		return readOrbitSoftwareList(spec, privateKeyPath)
+	}
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, err
+	}
+	return parseSoftwareList(data)
+}
+
+// softwareVersionChange 是两个列表中版本不同的同一软件
+type softwareVersionChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// softwareDiff 是两个软件列表的差异
+type softwareDiff struct {
+	Added   []Software              `json:"added"`
+	Removed []Software              `json:"removed"`
+	Changed []softwareVersionChange `json:"changed"`
+}
+
+// Empty 判断两个列表是否相同
+func (d softwareDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// softwareKey 是比较软件列表时使用的标识：有包 ID 时使用 softwareIdentity，否则使用完整名称，
+// 以免 "Python 3.11" 和 "Python 3.12" 这样只有版本不同的条目被当成同一个软件
+func softwareKey(item Software) string {
+	if strings.TrimSpace(item.ID) != "" {
+		return softwareIdentity(item)
+	}
+	return strings.ToLower(strings.TrimSpace(item.Name))
+}
+
+// pairSoftware 把 to 中的条目与 from 中的同一软件配对，返回 to 下标到 from 下标的映射。
+// 先按 softwareKey 精确配对，剩下的条目再像 buildInstallPlan 一样用 sameSoftware 配对，
+// 这样 ID 或来源变化（例如注册表条目合并了 winget 包 ID）的软件不会被当成删除后重新安装
+func pairSoftware(from, to []Software) map[int]int {
+	unpaired := make(map[string][]int)
+	for i, item := range from {
+		key := softwareKey(item)
+		unpaired[key] = append(unpaired[key], i)
+	}
+
+	pairs := make(map[int]int)
+	paired := make(map[int]bool)
+	for j, item := range to {
+		key := softwareKey(item)
+		if candidates := unpaired[key]; len(candidates) > 0 {
+			pairs[j] = candidates[0]
+			paired[candidates[0]] = true
+			unpaired[key] = candidates[1:]
+		}
+	}
+
+	for j, item := range to {
+		if _, ok := pairs[j]; ok {
+			continue
+		}
+		for i, old := range from {
+			if !paired[i] && sameSoftware(old, item) {
+				pairs[j] = i
+				paired[i] = true
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// diffSoftware 比较 from 和 to，报告新增、删除和版本变化的软件
+func diffSoftware(from, to []Software) softwareDiff {
+	pairs := pairSoftware(from, to)
+	paired := make(map[int]bool)
+
+	diff := softwareDiff{Added: []Software{}, Removed: []Software{}, Changed: []softwareVersionChange{}}
+	for j, item := range to {
+		i, ok := pairs[j]
+		if !ok {
+			diff.Added = append(diff.Added, item)
+			continue
+		}
+		paired[i] = true
+		if old := from[i]; old.Version != item.Version {
+			diff.Changed = append(diff.Changed, softwareVersionChange{Name: item.Name, From: old.Version, To: item.Version})
+		}
+	}
+	for i, item := range from {
+		if !paired[i] {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+
+	sortSoftware(diff.Added)
+	sortSoftware(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return strings.ToLower(diff.Changed[i].Name) < strings.ToLower(diff.Changed[j].Name)
+	})
+	return diff
+}
+
+// writeSoftwareDiff 以 table 或 json 格式输出差异
+func writeSoftwareDiff(w io.Writer, diff softwareDiff, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	case "table", "":
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (可用 table、json)", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tNAME\tFROM\tTO")
+	for _, item := range diff.Added {
+		fmt.Fprintf(tw, "+\t%s\t\t%s\n", item.Name, item.Version)
+	}
+	for _, item := range diff.Removed {
+		fmt.Fprintf(tw, "-\t%s\t%s\t\n", item.Name, item.Version)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(tw, "~\t%s\t%s\t%s\n", change.Name, change.From, change.To)
+	}
+	return tw.Flush()
+}
+
+// software 命令的参数
+var (
+	softwareListFormat   string
+	softwareExportFormat string
+	softwareExportFrom   string
+	softwareDiffFormat   string
+	softwarePrivateKey   string // 解密加密备份的私钥
+	softwareListQuery    softwareQuery
+)
+
+// softwareCmd 是已安装软件相关命令的父命令
+var softwareCmd = &cobra.Command{
+	Use:   "software",
+	Short: "Inspect, export and compare the installed software inventory",
+}
+
+// softwareListCmd 列出已安装软件
+var softwareListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed software",
+	Long: `Scan the installed software and print it as a table, JSON or CSV.
+
+Entries excluded by software.excluded_patterns and the built-in rules are left
+out unless --all is given; see 'orbit software explain'.
+
+Examples:
+  orbit software list --name python
+  orbit software list --source winget --source scoop --format csv`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		software, err := scanSoftware(cmd.Context(), softwareListQuery)
+		if err != nil {
+			logger.Errorf("获取已安装软件失败: %v", err)
+			os.Exit(1)
+		}
+		if err := writeSoftware(os.Stdout, software, softwareListFormat); err != nil {
+			logger.Errorf("输出软件列表失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// softwareExportCmd 导出已安装软件列表
+var softwareExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the installed software list",
+	Long: `Write the installed software list to a file (or standard output), in the
+same JSON format as software-list.json in backups, or as CSV. The result can be
+compared with 'orbit software diff'.
+
+With --from, export the software list of a backup (or of an earlier export)
+instead of this machine. Encrypted backups are decrypted in memory with
+--private-key, or with encryption.private_key_path from the configuration.
+
+The list can also be written in the import format of another package manager:
+  winget     packages.json for 'winget import'
+  choco      packages.config for 'choco install packages.config'
+  brewfile   Brewfile for 'brew bundle'
+  apt        selections for 'dpkg --set-selections' and 'apt-get dselect-upgrade'
+
+Apps from that package manager keep their own package ID. Other apps are
+looked up by name, alias or package ID in software-packages.json and then in a
+built-in table; apps without a package are listed as skipped. The mapping
+file looks like:
+  {"packages": [{"name": "Git", "aliases": ["Git for Windows"],
+    "winget": "Git.Git", "choco": "git", "brew": "git", "apt": "git"}]}
+
+Examples:
+  orbit software export software.json
+  orbit software export software.csv --format csv
+  orbit software export packages.json --format winget --from backup.orbit
+  orbit software export Brewfile --format brewfile`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := softwareExportFormat
+		manifest := containsString(manifestFormats, format)
+		if format != "json" && format != "csv" && !manifest {
+			logger.Errorf("不支持的导出格式: %s (可用 json、csv、%s)", format, strings.Join(manifestFormats, "、"))
+			os.Exit(1)
+		}
+
+		software, err := exportedSoftware(cmd.Context())
+		if err != nil {
+			logger.Errorf("获取软件列表失败: %v", err)
+			os.Exit(1)
+		}
+
+		var buf bytes.Buffer
+		if manifest {
+			mappings, err := loadPackageMappings(PackageMappingFileName)
+			if err != nil {
+				logger.Errorf("加载包 ID 映射表失败: %v", err)
+				os.Exit(1)
+			}
+			skipped, err := writePackageManifest(&buf, software, format, mappings)
+			if err != nil {
+				logger.Errorf("导出软件列表失败: %v", err)
+				os.Exit(1)
+			}
+			if len(skipped) > 0 {
+				names := make([]string, len(skipped))
+				for i, item := range skipped {
+					names[i] = item.Name
+				}
+				logger.Warnf("%d 个软件没有 %s 包，已跳过 (可以在 %s 中添加映射): %s",
+					len(skipped), format, PackageMappingFileName, strings.Join(names, ", "))
+			}
+		} else if err := writeSoftware(&buf, software, format); err != nil {
+			logger.Errorf("导出软件列表失败: %v", err)
+			os.Exit(1)
+		}
+
+		if len(args) == 0 {
+			os.Stdout.Write(buf.Bytes())
+			return
+		}
+		if err := os.WriteFile(args[0], buf.Bytes(), 0644); err != nil {
+			logger.Errorf("写入 %s 失败: %v", args[0], err)
+			os.Exit(1)
+		}
+		logger.Infof("已导出 %d 个软件到 %s", len(software), args[0])
+	},
+}
+
+// exportedSoftware 返回要导出的软件：默认扫描本机，指定 --from 时读取备份或导出的文件
+func exportedSoftware(ctx context.Context) ([]Software, error) {
+	if softwareExportFrom == "" {
+		return scanSoftware(ctx, softwareListQuery)
+	}
+
+	snapshot, err := loadSoftwareSnapshot(ctx, softwareExportFrom, softwarePrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	var result []Software
+	for _, item := range snapshot {
+		if softwareListQuery.Match(item) {
+			result = append(result, item)
+		}
+	}
+	sortSoftware(result)
+	return result, nil
+}
+
+// softwareDiffCmd 比较两个软件列表
+var softwareDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare two software lists",
+	Long: `Compare two software lists and report apps that were added (+), removed (-)
+or changed version (~) going from <a> to <b>.
+
+Each side is one of:
+  live          the software installed on this machine now
+  FILE.orbit    the software list stored in a backup
+  FILE          a list written by 'orbit software export' (JSON or CSV)
+
+Encrypted backups are decrypted in memory with --private-key, or with
+encryption.private_key_path from the configuration.
+
+Examples:
+  orbit software diff backup.orbit live
+  orbit software diff old.json new.json --format json`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, err := loadSoftwareSnapshot(cmd.Context(), args[0], softwarePrivateKey)
+		if err != nil {
+			logger.Errorf("读取 %s 失败: %v", args[0], err)
+			os.Exit(1)
+		}
+		to, err := loadSoftwareSnapshot(cmd.Context(), args[1], softwarePrivateKey)
+		if err != nil {
+			logger.Errorf("读取 %s 失败: %v", args[1], err)
+			os.Exit(1)
+		}
+
+		diff := diffSoftware(from, to)
+		if diff.Empty() && softwareDiffFormat != "json" {
+			logger.Info("两个软件列表相同")
+			return
+		}
+		if err := writeSoftwareDiff(os.Stdout, diff, softwareDiffFormat); err != nil {
+			logger.Errorf("输出差异失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(softwareCmd)
+	softwareCmd.AddCommand(softwareListCmd)
+	softwareCmd.AddCommand(softwareExportCmd)
+	softwareCmd.AddCommand(softwareDiffCmd)
+
+	softwareListCmd.Flags().StringVar(&softwareListFormat, "format", "table", "Output format: table, json or csv")
+	softwareExportCmd.Flags().StringVar(&softwareExportFormat, "format", "json", "Output format: json, csv, winget, choco, brewfile or apt")
+	softwareExportCmd.Flags().StringVar(&softwareExportFrom, "from", "", "Export the software list of this .orbit backup or exported file instead of this machine")
+	softwareDiffCmd.Flags().StringVar(&softwareDiffFormat, "format", "table", "Output format: table or json")
+	for _, c := range []*cobra.Command{softwareExportCmd, softwareDiffCmd} {
+		c.Flags().StringVar(&softwarePrivateKey, "private-key", "", "Private key for encrypted backups (default: encryption.private_key_path)")
+	}
+	for _, c := range []*cobra.Command{softwareListCmd, softwareExportCmd} {
+		c.Flags().StringVar(&softwareListQuery.Name, "name", "", "Only include software whose name contains this text")
+		c.Flags().StringVar(&softwareListQuery.Publisher, "publisher", "", "Only include software whose publisher contains this text")
+		c.Flags().StringSliceVar(&softwareListQuery.Sources, "source", nil, "Only include software from this inventory source (repeatable), e.g. registry, winget, dpkg")
+		c.Flags().BoolVar(&softwareListQuery.All, "all", false, "Include entries excluded by software.excluded_patterns and the built-in rules")
+	}
+}
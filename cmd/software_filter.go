@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Skip rule origins shown by orbit software explain
+const (
+	ruleOriginConfig  = "config"
+	ruleOriginBuiltin = "built-in"
+)
+
+// builtinSkipPatterns hide runtimes, updates and other system components. They are
+// evaluated after the patterns from software.excluded_patterns, so a "!" rule in the
+// config can bring back an entry they would drop.
+var builtinSkipPatterns = []string{
+	"glob:Microsoft Visual C++ *",
+	"glob:Microsoft .NET *",
+	`regex:^Microsoft (Windows Desktop|ASP\.NET Core|Edge WebView2) Runtime\b`,
+	"Windows SDK",
+	"Update for",
+	"Security Update",
+	"Hotfix",
+	"Service Pack",
+	// Windows update IDs such as KB5034441, not names that merely contain "KB"
+	`regex:\bKB\d{6,7}\b`,
+	"Redistributable",
+	"System Component",
+	// Very short ASCII names are usually system components
+	`regex:^[[:ascii:]]{0,2}$`,
+}
+
+// skipRule is one parsed exclusion (or inclusion) pattern.
+//
+// Pattern syntax, case-insensitive:
+//
+//	Steam             substring of the name
+//	Mozilla*          glob on the whole name when the pattern contains * or ?
+//	glob:Steam*       glob on the whole name
+//	regex:^KB\d+$     regular expression on the name
+//	publisher:Valve*  glob (or substring, without wildcards) on the publisher
+//	!pattern          keep entries matching pattern even if a later rule excludes them
+type skipRule struct {
+	Pattern string // the pattern as written
+	Origin  string // ruleOriginConfig or ruleOriginBuiltin
+	Include bool   // "!" rule
+	field   string // "name" or "publisher"
+	re      *regexp.Regexp
+}
+
+// parseSkipRule parses one pattern
+func parseSkipRule(pattern, origin string) (skipRule, error) {
+	rule := skipRule{Pattern: pattern, Origin: origin, field: "name"}
+
+	body := strings.TrimSpace(pattern)
+	if strings.HasPrefix(body, "!") {
+		rule.Include = true
+		body = strings.TrimSpace(body[1:])
+	}
+
+	kind, value, ok := strings.Cut(body, ":")
+	kind = strings.ToLower(kind)
+	switch kind {
+	case "publisher":
+		rule.field = "publisher"
+		body = value
+	case "glob", "regex", "re", "substr":
+	default:
+		ok = false
+	}
+	if body == "" || (ok && value == "") {
+		return rule, fmt.Errorf("空的匹配模式: %q", pattern)
+	}
+
+	var expr string
+	switch {
+	case ok && (kind == "regex" || kind == "re"):
+		expr = value
+	case ok && kind == "substr":
+		expr = regexp.QuoteMeta(value)
+	case ok && kind == "glob":
+		expr = globToRegexp(value)
+	case strings.ContainsAny(body, "*?"):
+		expr = globToRegexp(body)
+	default:
+		expr = regexp.QuoteMeta(body)
+	}
+
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return rule, fmt.Errorf("无效的匹配模式 %q: %v", pattern, err)
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// globToRegexp converts a glob with * and ? into an anchored regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// Matches reports whether the rule matches the entry
+func (r skipRule) Matches(item Software) bool {
+	value := item.Name
+	if r.field == "publisher" {
+		value = item.Publisher
+		if value == "" {
+			return false
+		}
+	}
+	return r.re.MatchString(strings.TrimSpace(value))
+}
+
+// skipDecision is the result of running an entry through the filter
+type skipDecision struct {
+	Skip bool
+	Rule *skipRule // the rule that decided, nil when no rule matched
+}
+
+// softwareFilter decides which inventory entries are left out of the software list
+type softwareFilter struct {
+	rules []skipRule
+}
+
+// newSoftwareFilter builds the filter from software.excluded_patterns followed by
+// the built-in rules. Invalid patterns are returned as errors and ignored.
+func newSoftwareFilter(patterns []string) (*softwareFilter, []error) {
+	filter := &softwareFilter{}
+	var errs []error
+
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		rule, err := parseSkipRule(pattern, ruleOriginConfig)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		filter.rules = append(filter.rules, rule)
+	}
+
+	for _, pattern := range builtinSkipPatterns {
+		rule, err := parseSkipRule(pattern, ruleOriginBuiltin)
+		if err != nil {
+			panic(err)
+		}
+		filter.rules = append(filter.rules, rule)
+	}
+	return filter, errs
+}
+
+// configuredSoftwareFilter builds the filter from the loaded configuration
+func configuredSoftwareFilter() *softwareFilter {
+	filter, errs := newSoftwareFilter(currentSoftwareConfig().ExcludedPatterns)
+	for _, err := range errs {
+		logger.Warnf("忽略排除模式: %v", err)
+	}
+	return filter
+}
+
+// Explain returns the first rule matching the entry; entries no rule matches are kept
+func (f *softwareFilter) Explain(item Software) skipDecision {
+	if f == nil {
+		return skipDecision{}
+	}
+	for i := range f.rules {
+		if f.rules[i].Matches(item) {
+			return skipDecision{Skip: !f.rules[i].Include, Rule: &f.rules[i]}
+		}
+	}
+	return skipDecision{}
+}
+
+// Skip reports whether the entry should be left out
+func (f *softwareFilter) Skip(item Software) bool {
+	return f.Explain(item).Skip
+}
+
+// invalidSkipPatterns returns the patterns that cannot be parsed, used by config validate
+func invalidSkipPatterns(patterns []string) []string {
+	var invalid []string
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		if _, err := parseSkipRule(pattern, ruleOriginConfig); err != nil {
+			invalid = append(invalid, pattern)
+		}
+	}
+	return invalid
+}
+
+// describeDecision formats a decision for orbit software explain
+func describeDecision(decision skipDecision) string {
+	if decision.Rule == nil {
+		return "保留 (没有匹配的规则)"
+	}
+	action := "排除"
+	if !decision.Skip {
+		action = "保留"
+	}
+	return fmt.Sprintf("%s (%s 规则 %q)", action, decision.Rule.Origin, decision.Rule.Pattern)
+}
+
+// explain 假设条目的发布者
+var explainPublisher string
+
+// softwareExplainCmd 显示某个软件被哪条规则保留或排除
+var softwareExplainCmd = &cobra.Command{
+	Use:   "explain <name>",
+	Short: "Show which rule includes or excludes a software entry",
+	Long: `Scan the installed software and show, for every entry whose name contains
+<name>, whether it is kept in the software list and which rule decided.
+
+When nothing installed matches, <name> (and --publisher) is evaluated as if it
+were installed, which is handy for testing a new pattern.
+
+Rules come from software.excluded_patterns, followed by the built-in rules.
+The first matching rule wins. Pattern syntax:
+  Steam             substring of the name
+  Mozilla*          glob on the whole name (when it contains * or ?)
+  glob:Steam*       glob on the whole name
+  regex:^KB\d+$     regular expression on the name
+  publisher:Valve*  match the publisher instead of the name
+  !pattern          keep matching entries even if a later rule excludes them
+
+Examples:
+  orbit software explain "Java"
+  orbit config add software.excluded_patterns "publisher:Valve*"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := configuredSoftwareFilter()
+
+		// 不过滤地扫描，才能看到被排除的条目
+		installed, err := collectInventory(cmd.Context(), inventorySources(currentSoftwareConfig()), nil, InventorySourceTimeout)
+		if err != nil {
+			logger.Errorf("获取已安装软件失败: %v", err)
+			os.Exit(1)
+		}
+
+		query := strings.ToLower(args[0])
+		var matched []Software
+		for _, item := range installed {
+			if strings.Contains(strings.ToLower(item.Name), query) {
+				matched = append(matched, item)
+			}
+		}
+		if len(matched) == 0 {
+			logger.Infof("没有已安装的软件名称包含 %q，按假设的条目判断:", args[0])
+			matched = []Software{{Name: args[0], Publisher: explainPublisher}}
+		}
+
+		for _, item := range matched {
+			title := item.Name
+			if item.Version != "" {
+				title += " " + item.Version
+			}
+			if item.Publisher != "" {
+				title += " [" + item.Publisher + "]"
+			}
+			if item.Source != "" {
+				title += " (" + item.Source + ")"
+			}
+			logger.Infof("%s: %s", title, describeDecision(filter.Explain(item)))
+		}
+	},
+}
+
+func init() {
+	softwareCmd.AddCommand(softwareExplainCmd)
+
+	softwareExplainCmd.Flags().StringVar(&explainPublisher, "publisher", "", "Publisher of the hypothetical entry when nothing installed matches")
+}
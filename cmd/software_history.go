@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SoftwareHistoryFileName 是已安装软件的历史记录，与 info.json 位于同一目录。第一行是完整的
+// 软件列表，之后每次 save 只追加与上一次相比的变化，文件只随变化增长。
+const SoftwareHistoryFileName = "software-history.jsonl"
+
+// 软件时间线中的事件类型
+const (
+	SoftwareAppeared    = "appeared"
+	SoftwareChanged     = "changed"
+	SoftwareDisappeared = "disappeared"
+)
+
+// softwareSnapshot 是某次 save 时的完整软件列表，由历史记录重放得到
+type softwareSnapshot struct {
+	Timestamp string     `json:"timestamp"` // RFC3339
+	Software  []Software `json:"software"`
+}
+
+// softwareHistoryRecord 是历史记录中的一行。Upserted 和 Removed 都为空时是完整的列表
+// （第一行，以及旧版本写入的每一行），否则只记录新增或变化的条目和消失的条目。
+type softwareHistoryRecord struct {
+	Timestamp string     `json:"timestamp"` // RFC3339
+	Software  []Software `json:"software,omitempty"`
+	Upserted  []Software `json:"upserted,omitempty"`
+	Removed   []Software `json:"removed,omitempty"` // 只保存 softwareKey 需要的字段
+}
+
+// softwareEvent 是时间线中的一次变化
+type softwareEvent struct {
+	Time        string `json:"time"` // 检测到变化的快照时间
+	Kind        string `json:"event"`
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	FromVersion string `json:"fromVersion,omitempty"` // changed 事件变化前的版本
+	InstallDate string `json:"installDate,omitempty"` // 来源报告的安装日期，InstallDateLayout 格式
+	Size        uint64 `json:"size,omitempty"`        // 安装大小，单位 KB
+	Source      string `json:"source,omitempty"`
+}
+
+// softwareHistoryPath 返回历史记录的路径，没有加载配置时返回空字符串
+func softwareHistoryPath() string {
+	configManager := GetConfigManager()
+	if configManager == nil || !configManager.IsConfigLoaded() {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configManager.configPath), SoftwareHistoryFileName)
+}
+
+// snapshotSoftware 只保留历史记录需要的字段，避免卸载命令等内容让记录无限变大
+func snapshotSoftware(software []Software) []Software {
+	result := make([]Software, len(software))
+	for i, item := range software {
+		result[i] = Software{
+			Name:          item.Name,
+			ID:            item.ID,
+			Version:       item.Version,
+			Publisher:     item.Publisher,
+			InstallDate:   item.InstallDate,
+			Source:        item.Source,
+			EstimatedSize: item.EstimatedSize,
+		}
+	}
+	sortSoftware(result)
+	return result
+}
+
+// readSoftwareHistory 读取历史记录并重放为每次 save 的完整列表，文件不存在时返回空列表；
+// 无法解析的行会被忽略
+func readSoftwareHistory(path string) ([]softwareSnapshot, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshots []softwareSnapshot
+	state := make(map[string]Software)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record softwareHistoryRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			logger.Warnf("忽略无法解析的软件历史记录: %v", err)
+			continue
+		}
+
+		if len(record.Upserted) == 0 && len(record.Removed) == 0 {
+			state = make(map[string]Software)
+			for _, item := range record.Software {
+				state[softwareKey(item)] = item
+			}
+		} else {
+			for _, item := range record.Removed {
+				delete(state, softwareKey(item))
+			}
+			for _, item := range record.Upserted {
+				state[softwareKey(item)] = item
+			}
+		}
+
+		software := make([]Software, 0, len(state))
+		for _, item := range state {
+			software = append(software, item)
+		}
+		sortSoftware(software)
+		snapshots = append(snapshots, softwareSnapshot{Timestamp: record.Timestamp, Software: software})
+	}
+	return snapshots, scanner.Err()
+}
+
+// softwareHistoryChanges 返回从 previous 到 current 新增或变化的条目和消失的条目。
+// 条目用 pairSoftware 配对；只有 ID 或来源变化时，旧条目记为消失，回放时不会同时保留两条。
+func softwareHistoryChanges(previous, current []Software) (upserted, removed []Software) {
+	pairs := pairSoftware(previous, current)
+	paired := make(map[int]bool)
+	for j, item := range current {
+		i, ok := pairs[j]
+		if !ok {
+			upserted = append(upserted, item)
+			continue
+		}
+		paired[i] = true
+		if old := previous[i]; old != item {
+			upserted = append(upserted, item)
+			if softwareKey(old) != softwareKey(item) {
+				removed = append(removed, Software{Name: old.Name, ID: old.ID, Source: old.Source})
+			}
+		}
+	}
+	for i, item := range previous {
+		if !paired[i] {
+			removed = append(removed, Software{Name: item.Name, ID: item.ID, Source: item.Source})
+		}
+	}
+	return upserted, removed
+}
+
+// appendSoftwareSnapshot 记录一次快照：没有历史时写入完整列表，之后只写入与上一次快照相比的
+// 变化，没有变化时不记录。返回是否写入了新的记录。
+func appendSoftwareSnapshot(path string, software []Software, now time.Time) (bool, error) {
+	lock, err := lockFile(path)
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	snapshots, err := readSoftwareHistory(path)
+	if err != nil {
+		return false, err
+	}
+	record := softwareHistoryRecord{Timestamp: now.Format(time.RFC3339)}
+	current := snapshotSoftware(software)
+	if len(snapshots) == 0 {
+		record.Software = current
+	} else {
+		record.Upserted, record.Removed = softwareHistoryChanges(snapshots[len(snapshots)-1].Software, current)
+		if len(record.Upserted) == 0 && len(record.Removed) == 0 {
+			return false, nil
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// recordSoftwareHistory 在 save 时记录软件快照，没有加载配置时跳过
+func recordSoftwareHistory(software []Software) {
+	path := softwareHistoryPath()
+	if path == "" {
+		logger.Debug("没有加载配置，不记录软件历史")
+		return
+	}
+	if _, err := appendSoftwareSnapshot(path, software, time.Now()); err != nil {
+		logger.Warnf("记录软件历史失败: %v", err)
+	}
+}
+
+// softwareTimeline 比较相邻的快照，返回按时间排序的变化。第一次快照中的软件都记为 appeared。
+func softwareTimeline(snapshots []softwareSnapshot) []softwareEvent {
+	var events []softwareEvent
+	var previous []Software
+
+	for _, snapshot := range snapshots {
+		// 与 diffSoftware 一样配对，ID 或来源变化的同一软件不算消失后重新出现
+		pairs := pairSoftware(previous, snapshot.Software)
+		paired := make(map[int]bool)
+
+		var changes []softwareEvent
+		for j, item := range snapshot.Software {
+			i, ok := pairs[j]
+			if !ok {
+				changes = append(changes, newSoftwareEvent(snapshot.Timestamp, SoftwareAppeared, item))
+				continue
+			}
+			paired[i] = true
+			if old := previous[i]; old.Version != item.Version {
+				event := newSoftwareEvent(snapshot.Timestamp, SoftwareChanged, item)
+				event.FromVersion = old.Version
+				changes = append(changes, event)
+			}
+		}
+		for i, item := range previous {
+			if !paired[i] {
+				changes = append(changes, newSoftwareEvent(snapshot.Timestamp, SoftwareDisappeared, item))
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			return strings.ToLower(changes[i].Name) < strings.ToLower(changes[j].Name)
+		})
+
+		events = append(events, changes...)
+		previous = snapshot.Software
+	}
+	return events
+}
+
+func newSoftwareEvent(timestamp, kind string, item Software) softwareEvent {
+	return softwareEvent{
+		Time:        timestamp,
+		Kind:        kind,
+		Name:        item.Name,
+		Version:     item.Version,
+		InstallDate: item.InstallDate,
+		Size:        item.EstimatedSize,
+		Source:      item.Source,
+	}
+}
+
+// formatSizeKB 格式化以 KB 为单位的大小
+func formatSizeKB(size uint64) string {
+	switch {
+	case size == 0:
+		return ""
+	case size < 1024:
+		return fmt.Sprintf("%d KB", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024))
+}
+
+// writeSoftwareTimeline 以 table 或 json 格式输出时间线
+func writeSoftwareTimeline(w io.Writer, events []softwareEvent, format string) error {
+	switch format {
+	case "json":
+		if events == nil {
+			events = []softwareEvent{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(events)
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DATE\tEVENT\tNAME\tVERSION\tINSTALLED\tSIZE")
+		for _, event := range events {
+			date := event.Time
+			if t, err := time.Parse(time.RFC3339, event.Time); err == nil {
+				date = t.Local().Format("2006-01-02 15:04")
+			}
+			version := event.Version
+			if event.Kind == SoftwareChanged {
+				version = event.FromVersion + " -> " + event.Version
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", date, event.Kind, event.Name, version, event.InstallDate, formatSizeKB(event.Size))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("不支持的输出格式: %s (可用 table、json)", format)
+}
+
+// software history 的输出格式
+var softwareHistoryFormat string
+
+// softwareHistoryCmd 显示已安装软件的变化时间线
+var softwareHistoryCmd = &cobra.Command{
+	Use:   "history [name]",
+	Short: "Show when software appeared, changed version or disappeared",
+	Long: `Show the timeline of the installed software recorded by 'orbit save'.
+
+Every save records the software list in software-history.jsonl next to
+info.json: the full list the first time, afterwards only what changed since
+the previous save (nothing when the list is unchanged). This
+command compares consecutive snapshots and lists when each app appeared,
+changed version or disappeared, with the install date and size reported by
+its installer. Apps in the first snapshot are listed as appeared.
+
+With [name], only apps whose name contains it are shown.
+
+Examples:
+  orbit software history
+  orbit software history firefox --format json`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := softwareHistoryPath()
+		if path == "" {
+			logger.Error("没有加载配置，无法读取软件历史")
+			os.Exit(1)
+		}
+		snapshots, err := readSoftwareHistory(path)
+		if err != nil {
+			logger.Errorf("读取软件历史失败: %v", err)
+			os.Exit(1)
+		}
+		if len(snapshots) == 0 {
+			logger.Info("还没有软件历史记录，运行 orbit save 时会自动记录")
+			return
+		}
+
+		events := softwareTimeline(snapshots)
+		if len(args) == 1 {
+			query := strings.ToLower(args[0])
+			var matched []softwareEvent
+			for _, event := range events {
+				if strings.Contains(strings.ToLower(event.Name), query) {
+					matched = append(matched, event)
+				}
+			}
+			events = matched
+		}
+
+		if err := writeSoftwareTimeline(os.Stdout, events, softwareHistoryFormat); err != nil {
+			logger.Errorf("输出软件历史失败: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	softwareCmd.AddCommand(softwareHistoryCmd)
+
+	softwareHistoryCmd.Flags().StringVar(&softwareHistoryFormat, "format", "table", "Output format: table or json")
+}
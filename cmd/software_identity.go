@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// packageManagerSources are the inventory sources whose entries only carry a package
+// ID, which mergePackageEntries attaches to the registry entry of the same program
+var packageManagerSources = map[string]bool{"winget": true, "chocolatey": true, "scoop": true}
+
+// identityNamespaces maps an inventory source to the namespace of its package IDs.
+// Registry entries are split further by softwareIdentity: subkeys that are GUIDs are
+// MSI ProductCodes.
+var identityNamespaces = map[string]string{
+	"registry":      "registry",
+	"winget":        "winget",
+	"chocolatey":    "choco",
+	"scoop":         "scoop",
+	"appx":          "appx",
+	"dpkg":          "dpkg",
+	"rpm":           "rpm",
+	"flatpak":       "flatpak",
+	"snap":          "snap",
+	"applications":  "bundle",
+	"homebrew":      "brew",
+	"homebrew-cask": "cask",
+}
+
+// productCodeRegexp matches an MSI ProductCode such as {23170F69-40C1-2702-2301-000001000000}
+var productCodeRegexp = regexp.MustCompile(`^\{[0-9A-Fa-f]{8}(-[0-9A-Fa-f]{4}){3}-[0-9A-Fa-f]{12}\}$`)
+
+// softwareIdentity returns a stable key for an inventory entry, e.g. "winget:git.git",
+// "msi:{23170f69-...}" or "dpkg:git". Entries without a package ID fall back to
+// "name:" plus the normalized name.
+func softwareIdentity(item Software) string {
+	id := strings.TrimSpace(item.ID)
+	if id == "" {
+		return "name:" + normalizeSoftwareName(item.Name)
+	}
+
+	namespace, ok := identityNamespaces[item.Source]
+	if !ok {
+		namespace = strings.ToLower(item.Source)
+	}
+	if namespace == "registry" && productCodeRegexp.MatchString(id) {
+		namespace = "msi"
+	}
+	return namespace + ":" + strings.ToLower(id)
+}
+
+// registryIdentity returns the identity of the registry entry an item was read from,
+// e.g. "msi:{23170f69-...}", which mergePackageEntries keeps in RegistryKey when the
+// item takes a package ID. It is "" for items not read from the registry.
+func registryIdentity(item Software) string {
+	if item.RegistryKey == "" {
+		return ""
+	}
+	return softwareIdentity(Software{ID: item.RegistryKey, Source: "registry"})
+}
+
+// Tokens that describe a build rather than the product, stripped by normalizeSoftwareName
+var (
+	archTokenRegexp    = regexp.MustCompile(`^(x64|x86|x86_64|amd64|arm64|aarch64|ia32|win32|win64|64-bit|32-bit|64bit|32bit|x64-based|x86-based)$`)
+	localeTokenRegexp  = regexp.MustCompile(`^[a-z]{2}(-[a-z]{2,4})?$`)
+	versionTokenRegexp = regexp.MustCompile(`^v?\d+(\.\d+)+([-+._]?[a-z0-9]+)*$|^v?\d+$`)
+	bracketRegexp      = regexp.MustCompile(`\s*[(\[]([^()\[\]]*)[)\]]`)
+	spaceRegexp        = regexp.MustCompile(`\s+`)
+)
+
+// buildQualifiers are words that may appear next to architecture or locale, as in
+// "(x64 edition)" or "(User)"
+var buildQualifiers = map[string]bool{
+	"edition": true, "user": true, "machine": true, "bit": true, "version": true,
+}
+
+// isBuildToken reports whether a word only describes the architecture, locale or version
+func isBuildToken(token string, allowLocale bool) bool {
+	token = strings.Trim(token, ",;")
+	switch {
+	case token == "" || token == "-":
+		return true
+	case archTokenRegexp.MatchString(token), versionTokenRegexp.MatchString(token), buildQualifiers[token]:
+		return true
+	case allowLocale && localeTokenRegexp.MatchString(token):
+		return true
+	}
+	return false
+}
+
+// normalizeSoftwareName reduces a display name to the product name used for matching:
+// lower case, without architecture, locale or version suffixes. For example
+// "Mozilla Firefox (x64 en-US)" and "Mozilla Firefox 125.0.2" both become "mozilla firefox".
+func normalizeSoftwareName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	// Drop (...) and [...] groups made only of build tokens; a locale is only
+	// recognised inside brackets so that words like "go" or "vs" survive
+	name = bracketRegexp.ReplaceAllStringFunc(name, func(group string) string {
+		inner := bracketRegexp.FindStringSubmatch(group)[1]
+		for _, token := range strings.Fields(inner) {
+			if !isBuildToken(token, true) {
+				return group
+			}
+		}
+		return ""
+	})
+
+	// Drop trailing build tokens such as "23.01", "x64" or "- 8.0.4"
+	tokens := strings.Fields(name)
+	for len(tokens) > 1 && isBuildToken(tokens[len(tokens)-1], false) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return spaceRegexp.ReplaceAllString(strings.Join(tokens, " "), " ")
+}
+
+// SoftwareMatch declares how a catalog entry is recognised among installed software.
+// Any ID, alias or pattern match counts; when Publisher is set, name based matches
+// (alias, pattern and the catalog name itself) also require the publisher to match.
+type SoftwareMatch struct {
+	IDs       []string `json:"ids,omitempty"`       // identities such as "winget:Git.Git", "msi:{GUID}", "dpkg:git"
+	Aliases   []string `json:"aliases,omitempty"`   // other display names, compared after normalization
+	Patterns  []string `json:"patterns,omitempty"`  // regular expressions on the display name, case-insensitive
+	Publisher string   `json:"publisher,omitempty"` // substring of the publisher, case-insensitive
+}
+
+// softwareMatcher is a compiled SoftwareMatch for one catalog entry
+type softwareMatcher struct {
+	ids       map[string]bool
+	names     map[string]bool
+	patterns  []*regexp.Regexp
+	publisher string
+}
+
+// newSoftwareMatcher compiles the match rules of a catalog entry
+func newSoftwareMatcher(desired DesiredSoftware) (*softwareMatcher, error) {
+	m := &softwareMatcher{ids: make(map[string]bool), names: make(map[string]bool)}
+	m.names[normalizeSoftwareName(desired.Name)] = true
+
+	if desired.Match == nil {
+		return m, nil
+	}
+	for _, id := range desired.Match.IDs {
+		m.ids[strings.ToLower(strings.TrimSpace(id))] = true
+	}
+	for _, alias := range desired.Match.Aliases {
+		m.names[normalizeSoftwareName(alias)] = true
+	}
+	for _, pattern := range desired.Match.Patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern %q for %s: %v", pattern, desired.Name, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+	m.publisher = strings.ToLower(desired.Match.Publisher)
+	return m, nil
+}
+
+// Match reports whether an installed entry is the catalog software, and why. Both the
+// package identity and the registry identity of a merged entry are checked.
+func (m *softwareMatcher) Match(item Software) (bool, string) {
+	for _, identity := range []string{softwareIdentity(item), registryIdentity(item)} {
+		if identity != "" && m.ids[identity] {
+			return true, "id " + identity
+		}
+	}
+
+	if m.publisher != "" && !strings.Contains(strings.ToLower(item.Publisher), m.publisher) {
+		return false, ""
+	}
+	if name := normalizeSoftwareName(item.Name); m.names[name] {
+		return true, "name " + name
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(item.Name) {
+			return true, "pattern " + re.String()
+		}
+	}
+	return false, ""
+}
+
+// findInstalled returns the installed entry matching a catalog entry, or nil
+func findInstalled(desired DesiredSoftware, installed []Software) (*Software, string, error) {
+	matcher, err := newSoftwareMatcher(desired)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range installed {
+		if ok, reason := matcher.Match(installed[i]); ok {
+			return &installed[i], reason, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// sameSoftwareName compares two display names after normalization
+func sameSoftwareName(a, b string) bool {
+	return normalizeSoftwareName(a) == normalizeSoftwareName(b)
+}
+
+// sameSoftware reports whether two inventory entries, possibly from different machines,
+// are the same program: by identity when both come with a package ID from the same
+// source, otherwise by normalized name
+func sameSoftware(a, b Software) bool {
+	if a.ID != "" && b.ID != "" && strings.EqualFold(a.Source, b.Source) {
+		return softwareIdentity(a) == softwareIdentity(b)
+	}
+	return sameSoftwareName(a.Name, b.Name)
+}
+
+// compactNameRegexp matches the separators ignored when a package ID is compared with a
+// display name, so that "7zip" matches "7-Zip" and "Mozilla.Firefox" matches "Mozilla Firefox"
+var compactNameRegexp = regexp.MustCompile(`[\s._-]+`)
+
+// packageIDNames returns the compacted names a package ID may be displayed under: the
+// whole ID and, for winget style "Publisher.Product" IDs, the product part
+func packageIDNames(id string) []string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	names := []string{compactNameRegexp.ReplaceAllString(id, "")}
+	if i := strings.LastIndex(id, "."); i >= 0 && i < len(id)-1 {
+		names = append(names, compactNameRegexp.ReplaceAllString(id[i+1:], ""))
+	}
+	return names
+}
+
+// packageMatchesEntry reports whether a package manager entry is the program of a
+// registry entry, by the package mappings or by comparing its ID with the display name
+func packageMatchesEntry(pkg, entry Software, mappings []PackageMapping) bool {
+	identity := softwareIdentity(pkg)
+	for _, mapping := range mappings {
+		if containsString(mapping.identities(), identity) && mapping.matches(entry) {
+			return true
+		}
+	}
+	name := compactNameRegexp.ReplaceAllString(normalizeSoftwareName(entry.Name), "")
+	return containsString(packageIDNames(pkg.ID), name)
+}
+
+// mergePackageEntries folds winget, Chocolatey and Scoop entries into the registry entry
+// of the same program: the registry entry keeps its display name, publisher and uninstall
+// information and takes the package ID and source, while RegistryKey keeps the registry
+// identity for catalog rules such as "msi:{GUID}". A package entry becomes a standalone,
+// ID-named entry only when no registry entry matches.
+func mergePackageEntries(software []Software, mappings []PackageMapping) []Software {
+	var result []Software
+	var packages []Software
+	var registry []int // indexes of the registry entries in result
+	for _, item := range software {
+		switch {
+		case packageManagerSources[item.Source] && item.ID != "":
+			packages = append(packages, item)
+		case item.Source == "registry":
+			registry = append(registry, len(result))
+			fallthrough
+		default:
+			result = append(result, item)
+		}
+	}
+
+	claimed := make(map[int]bool)
+	for _, pkg := range packages {
+		match := -1
+		for _, i := range registry {
+			if packageMatchesEntry(pkg, result[i], mappings) {
+				match = i
+				break
+			}
+		}
+		// A second package manager reporting an already merged program adds nothing
+		switch {
+		case match < 0:
+			result = append(result, pkg)
+		case !claimed[match]:
+			claimed[match] = true
+			if result[match].RegistryKey == "" {
+				result[match].RegistryKey = result[match].ID
+			}
+			result[match].ID = pkg.ID
+			result[match].Source = pkg.Source
+			if result[match].Version == "" {
+				result[match].Version = pkg.Version
+			}
+		}
+	}
+	return result
+}
+
+// inventoryPackageMappings returns the package mappings used to merge inventory entries,
+// falling back to the built-in table when software-packages.json cannot be read
+func inventoryPackageMappings() []PackageMapping {
+	mappings, err := loadPackageMappings(PackageMappingFileName)
+	if err != nil {
+		logger.Warnf("Failed to load package mappings: %v", err)
+		return builtinPackageMappings
+	}
+	return mappings
+}
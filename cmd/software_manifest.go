@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageMappingFileName 是用户扩展的包 ID 映射表，与 software-catalog.json 一样位于当前目录
+const PackageMappingFileName = "software-packages.json"
+
+// 包管理器清单格式，orbit software export --format 使用这些名称
+const (
+	ManifestWinget   = "winget"   // winget import 使用的 packages.json
+	ManifestChoco    = "choco"    // choco install 使用的 packages.config
+	ManifestBrewfile = "brewfile" // brew bundle 使用的 Brewfile
+	ManifestApt      = "apt"      // dpkg --set-selections 使用的选择列表
+)
+
+// manifestFormats 是支持的清单格式
+var manifestFormats = []string{ManifestWinget, ManifestChoco, ManifestBrewfile, ManifestApt}
+
+// PackageMapping 把一个软件对应到各包管理器中的包 ID，没有对应的包时留空
+type PackageMapping struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"` // 其他显示名称，按 normalizeSoftwareName 比较
+	Winget  string   `json:"winget,omitempty"`
+	Choco   string   `json:"choco,omitempty"`
+	Brew    string   `json:"brew,omitempty"` // Homebrew formula
+	Cask    string   `json:"cask,omitempty"` // Homebrew cask，Brewfile 中优先于 formula
+	Apt     string   `json:"apt,omitempty"`
+}
+
+// PackageMappingTable 是 software-packages.json 的内容
+type PackageMappingTable struct {
+	Packages []PackageMapping `json:"packages"`
+}
+
+// builtinPackageMappings 是常见软件的包 ID，software-packages.json 中的条目先于它们匹配
+var builtinPackageMappings = []PackageMapping{
+	{Name: "Git", Winget: "Git.Git", Choco: "git", Brew: "git", Apt: "git"},
+	{Name: "Visual Studio Code", Aliases: []string{"Microsoft Visual Studio Code", "code"},
+		Winget: "Microsoft.VisualStudioCode", Choco: "vscode", Cask: "visual-studio-code", Apt: "code"},
+	{Name: "Mozilla Firefox", Aliases: []string{"Firefox"},
+		Winget: "Mozilla.Firefox", Choco: "firefox", Cask: "firefox", Apt: "firefox"},
+	{Name: "Google Chrome", Aliases: []string{"google-chrome-stable"},
+		Winget: "Google.Chrome", Choco: "googlechrome", Cask: "google-chrome", Apt: "google-chrome-stable"},
+	{Name: "7-Zip", Aliases: []string{"p7zip-full"}, Winget: "7zip.7zip", Choco: "7zip", Brew: "p7zip", Apt: "p7zip-full"},
+	{Name: "VLC media player", Aliases: []string{"VLC"}, Winget: "VideoLAN.VLC", Choco: "vlc", Cask: "vlc", Apt: "vlc"},
+	{Name: "Node.js", Aliases: []string{"nodejs", "node"}, Winget: "OpenJS.NodeJS", Choco: "nodejs", Brew: "node", Apt: "nodejs"},
+	{Name: "Python", Aliases: []string{"python3"}, Choco: "python", Brew: "python", Apt: "python3"},
+	{Name: "Go Programming Language", Aliases: []string{"Go", "golang"}, Winget: "GoLang.Go", Choco: "golang", Brew: "go", Apt: "golang"},
+	{Name: "Docker Desktop", Winget: "Docker.DockerDesktop", Choco: "docker-desktop", Cask: "docker"},
+	{Name: "Notepad++", Winget: "Notepad++.Notepad++", Choco: "notepadplusplus"},
+	{Name: "Steam", Winget: "Valve.Steam", Choco: "steam", Cask: "steam", Apt: "steam"},
+	{Name: "Slack", Winget: "SlackTechnologies.Slack", Choco: "slack", Cask: "slack"},
+	{Name: "Zoom", Aliases: []string{"Zoom Workplace"}, Winget: "Zoom.Zoom", Choco: "zoom", Cask: "zoom"},
+}
+
+// loadPackageMappings 读取用户的映射表并加上内置映射，文件不存在时只使用内置映射
+func loadPackageMappings(path string) ([]PackageMapping, error) {
+	mappings := append([]PackageMapping{}, builtinPackageMappings...)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mappings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var table PackageMappingTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return append(table.Packages, mappings...), nil
+}
+
+// identities 返回映射条目在各包管理器中的标识，形式与 softwareIdentity 相同
+func (m PackageMapping) identities() []string {
+	var ids []string
+	for namespace, id := range map[string]string{"winget": m.Winget, "choco": m.Choco, "brew": m.Brew, "cask": m.Cask, "dpkg": m.Apt} {
+		if id != "" {
+			ids = append(ids, namespace+":"+strings.ToLower(id))
+		}
+	}
+	return ids
+}
+
+// matches 判断映射条目是否对应 item：包 ID 相同，或者名称、别名相同
+func (m PackageMapping) matches(item Software) bool {
+	if item.ID != "" && containsString(m.identities(), softwareIdentity(item)) {
+		return true
+	}
+	name := normalizeSoftwareName(item.Name)
+	if normalizeSoftwareName(m.Name) == name {
+		return true
+	}
+	for _, alias := range m.Aliases {
+		if normalizeSoftwareName(alias) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestPackage 是清单中的一个包，Kind 只用于 Brewfile（brew 或 cask）
+type manifestPackage struct {
+	Kind string
+	ID   string
+}
+
+// resolvePackage 返回 item 在 format 对应的包管理器中的包。来自该包管理器本身的条目直接
+// 使用自己的 ID，其他条目通过映射表查找。
+func resolvePackage(item Software, format string, mappings []PackageMapping) (manifestPackage, bool) {
+	if item.ID != "" {
+		switch namespace := strings.SplitN(softwareIdentity(item), ":", 2)[0]; {
+		case format == ManifestWinget && namespace == "winget",
+			format == ManifestChoco && namespace == "choco",
+			format == ManifestApt && namespace == "dpkg":
+			return manifestPackage{ID: item.ID}, true
+		case format == ManifestBrewfile && (namespace == "brew" || namespace == "cask"):
+			return manifestPackage{Kind: namespace, ID: item.ID}, true
+		}
+	}
+
+	for _, mapping := range mappings {
+		if !mapping.matches(item) {
+			continue
+		}
+		switch {
+		case format == ManifestWinget && mapping.Winget != "":
+			return manifestPackage{ID: mapping.Winget}, true
+		case format == ManifestChoco && mapping.Choco != "":
+			return manifestPackage{ID: mapping.Choco}, true
+		case format == ManifestApt && mapping.Apt != "":
+			return manifestPackage{ID: mapping.Apt}, true
+		case format == ManifestBrewfile && mapping.Cask != "":
+			return manifestPackage{Kind: "cask", ID: mapping.Cask}, true
+		case format == ManifestBrewfile && mapping.Brew != "":
+			return manifestPackage{Kind: "brew", ID: mapping.Brew}, true
+		}
+	}
+	return manifestPackage{}, false
+}
+
+// wingetManifest 是 winget import 接受的 packages.json
+type wingetManifest struct {
+	Schema       string                 `json:"$schema"`
+	CreationDate string                 `json:"CreationDate"`
+	Sources      []wingetManifestSource `json:"Sources"`
+}
+
+type wingetManifestSource struct {
+	Packages      []wingetManifestPackage `json:"Packages"`
+	SourceDetails wingetSourceDetails     `json:"SourceDetails"`
+}
+
+type wingetManifestPackage struct {
+	PackageIdentifier string `json:"PackageIdentifier"`
+}
+
+type wingetSourceDetails struct {
+	Argument   string `json:"Argument"`
+	Identifier string `json:"Identifier"`
+	Name       string `json:"Name"`
+	Type       string `json:"Type"`
+}
+
+// chocoManifest 是 choco install 接受的 packages.config
+type chocoManifest struct {
+	XMLName  xml.Name               `xml:"packages"`
+	Packages []chocoManifestPackage `xml:"package"`
+}
+
+type chocoManifestPackage struct {
+	ID string `xml:"id,attr"`
+}
+
+// writePackageManifest 把软件列表写成 format 对应的清单，返回找不到包 ID 而跳过的条目。
+// 清单中不写版本号，由包管理器安装最新版本。
+func writePackageManifest(w io.Writer, software []Software, format string, mappings []PackageMapping) ([]Software, error) {
+	var packages []manifestPackage
+	var skipped []Software
+	seen := make(map[manifestPackage]bool)
+	for _, item := range software {
+		pkg, ok := resolvePackage(item, format, mappings)
+		if !ok {
+			skipped = append(skipped, item)
+			continue
+		}
+		key := manifestPackage{Kind: pkg.Kind, ID: strings.ToLower(pkg.ID)}
+		if !seen[key] {
+			seen[key] = true
+			packages = append(packages, pkg)
+		}
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		if packages[i].Kind != packages[j].Kind {
+			return packages[i].Kind == "brew"
+		}
+		return strings.ToLower(packages[i].ID) < strings.ToLower(packages[j].ID)
+	})
+
+	switch format {
+	case ManifestWinget:
+		source := wingetManifestSource{
+			Packages: []wingetManifestPackage{},
+			SourceDetails: wingetSourceDetails{
+				Argument:   "https://cdn.winget.microsoft.com/cache",
+				Identifier: "Microsoft.Winget.Source_8wekyb3d8bbwe",
+				Name:       "winget",
+				Type:       "Microsoft.PreIndexed.Package",
+			},
+		}
+		for _, pkg := range packages {
+			source.Packages = append(source.Packages, wingetManifestPackage{PackageIdentifier: pkg.ID})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return skipped, encoder.Encode(wingetManifest{
+			Schema:       "https://aka.ms/winget-packages.schema.2.0.json",
+			CreationDate: time.Now().Format(time.RFC3339),
+			Sources:      []wingetManifestSource{source},
+		})
+	case ManifestChoco:
+		manifest := chocoManifest{}
+		for _, pkg := range packages {
+			manifest.Packages = append(manifest.Packages, chocoManifestPackage{ID: pkg.ID})
+		}
+		data, err := xml.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return skipped, err
+		}
+		_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+		return skipped, err
+	case ManifestBrewfile:
+		for _, pkg := range packages {
+			if _, err := fmt.Fprintf(w, "%s %q\n", pkg.Kind, pkg.ID); err != nil {
+				return skipped, err
+			}
+		}
+		return skipped, nil
+	case ManifestApt:
+		for _, pkg := range packages {
+			if _, err := fmt.Fprintf(w, "%s\tinstall\n", pkg.ID); err != nil {
+				return skipped, err
+			}
+		}
+		return skipped, nil
+	}
+	return skipped, fmt.Errorf("不支持的清单格式: %s (可用 %s)", format, strings.Join(manifestFormats, "、"))
+}
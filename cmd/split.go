@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 分卷索引文件的头部标识
+var SplitIndexHeader string = "ORBIT_SPLIT_v1\n"
+
+// splitVolume 描述一个分卷文件
+type splitVolume struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// splitIndex 是写在 name.orbit 中的分卷索引
+type splitIndex struct {
+	TotalSize int64         `json:"totalSize"`
+	SHA256    string        `json:"sha256"`
+	Volumes   []splitVolume `json:"volumes"`
+}
+
+var volumeSuffixRegexp = regexp.MustCompile(`\.\d{3}$`)
+
+// parseSize 解析 "2G"、"700M"、"512KB" 这样的大小，单位按 1024 计算
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "IB")
+	str = strings.TrimSuffix(str, "B")
+
+	multiplier := int64(1)
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			str = str[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("无效的大小: %s", s)
+	}
+
+	size := int64(value * float64(multiplier))
+	if size < 1 {
+		return 0, fmt.Errorf("无效的大小: %s", s)
+	}
+	return size, nil
+}
+
+// volumeName 返回第 n 个分卷的文件名（从 1 开始）
+func volumeName(orbitFilePath string, n int) string {
+	return fmt.Sprintf("%s.%03d", orbitFilePath, n)
+}
+
+// splitOrbitFile 把 orbitFilePath 切分为若干分卷，并用分卷索引替换原文件
+func splitOrbitFile(orbitFilePath string, volumeSize int64) (*splitIndex, error) {
+	src, err := os.Open(orbitFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	index := &splitIndex{}
+	total := sha256.New()
+
+	for n := 1; ; n++ {
+		name := volumeName(orbitFilePath, n)
+		dst, err := os.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("创建分卷失败: %v", err)
+		}
+
+		h := sha256.New()
+		written, err := io.Copy(io.MultiWriter(dst, h, total), io.LimitReader(src, volumeSize))
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("写入分卷 %s 失败: %v", name, err)
+		}
+
+		// 原文件恰好是分卷大小的整数倍时，最后会多出一个空分卷
+		if written == 0 && n > 1 {
+			os.Remove(name)
+			break
+		}
+
+		index.Volumes = append(index.Volumes, splitVolume{
+			Name:   filepath.Base(name),
+			Size:   written,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+		})
+		index.TotalSize += written
+
+		if written < volumeSize {
+			break
+		}
+	}
+	index.SHA256 = hex.EncodeToString(total.Sum(nil))
+	src.Close()
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// 先写临时文件再替换，避免中途失败时丢失原备份
+	tmpPath := orbitFilePath + ".index.tmp"
+	if err := os.WriteFile(tmpPath, append([]byte(SplitIndexHeader), data...), 0644); err != nil {
+		return nil, fmt.Errorf("写入分卷索引失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, orbitFilePath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("写入分卷索引失败: %v", err)
+	}
+
+	// 删除之前分卷更多的备份留下的分卷
+	for n := len(index.Volumes) + 1; ; n++ {
+		if err := os.Remove(volumeName(orbitFilePath, n)); err != nil {
+			if !os.IsNotExist(err) {
+				logger.Warnf("删除旧分卷失败: %v", err)
+			}
+			break
+		}
+	}
+
+	return index, nil
+}
+
+// readSplitIndex 读取分卷索引；文件不是分卷索引时返回 nil
+func readSplitIndex(indexPath string) (*splitIndex, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(SplitIndexHeader))
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != SplitIndexHeader {
+		return nil, nil
+	}
+
+	var index splitIndex
+	if err := json.NewDecoder(f).Decode(&index); err != nil {
+		return nil, fmt.Errorf("解析分卷索引失败: %v", err)
+	}
+	if len(index.Volumes) == 0 {
+		return nil, fmt.Errorf("分卷索引中没有分卷")
+	}
+	return &index, nil
+}
+
+// findSplitIndexPath 根据用户给出的路径找到分卷索引：
+// 可以是 name.orbit 本身，也可以是任意一个 name.orbit.NNN 分卷
+func findSplitIndexPath(orbitFilePath string) string {
+	if volumeSuffixRegexp.MatchString(orbitFilePath) {
+		return volumeSuffixRegexp.ReplaceAllString(orbitFilePath, "")
+	}
+	return orbitFilePath
+}
+
+// joinSplitVolumes 按索引校验并拼接分卷到 dst
+func joinSplitVolumes(indexPath string, index *splitIndex, dst io.Writer) error {
+	dir := filepath.Dir(indexPath)
+
+	// 先按哈希建立对照表，用于识别顺序错乱的分卷
+	position := make(map[string]int, len(index.Volumes))
+	for i, v := range index.Volumes {
+		position[v.SHA256] = i + 1
+	}
+
+	total := sha256.New()
+	for i, v := range index.Volumes {
+		volumePath := filepath.Join(dir, v.Name)
+		f, err := os.Open(volumePath)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("缺少第 %d/%d 个分卷: %s", i+1, len(index.Volumes), v.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("打开分卷 %s 失败: %v", v.Name, err)
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(dst, h, total), f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("读取分卷 %s 失败: %v", v.Name, err)
+		}
+
+		sum := hex.EncodeToString(h.Sum(nil))
+		if sum != v.SHA256 {
+			if actual, ok := position[sum]; ok {
+				return fmt.Errorf("分卷顺序错误: %s 的内容属于第 %d 个分卷，应为第 %d 个", v.Name, actual, i+1)
+			}
+			return fmt.Errorf("分卷 %s 已损坏或不属于此备份 (校验和不匹配)", v.Name)
+		}
+	}
+
+	if hex.EncodeToString(total.Sum(nil)) != index.SHA256 {
+		return fmt.Errorf("合并后的备份校验和不匹配")
+	}
+	return nil
+}
+
+// resolveOrbitFile 在 read/load/restore 之前调用：
+// 普通 .orbit 文件原样返回；分卷备份会被校验并合并到临时文件，
+// 调用方在使用完毕后必须调用 cleanup。
+func resolveOrbitFile(orbitFilePath string) (string, func(), error) {
+	noop := func() {}
+
+	indexPath := findSplitIndexPath(orbitFilePath)
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		if indexPath != orbitFilePath {
+			return "", noop, fmt.Errorf("找不到分卷索引文件: %s", indexPath)
+		}
+		return orbitFilePath, noop, nil
+	}
+
+	index, err := readSplitIndex(indexPath)
+	if err != nil {
+		return "", noop, err
+	}
+	if index == nil {
+		// 不是分卷索引，按普通 .orbit 文件处理
+		return orbitFilePath, noop, nil
+	}
+
+	logger.Infof("检测到分卷备份: %s (%d 个分卷, 共 %d 字节)", indexPath, len(index.Volumes), index.TotalSize)
+
+	tempFile, err := os.CreateTemp("", "orbit_joined_*.orbit")
+	if err != nil {
+		return "", noop, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	cleanup := func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}
+
+	if err := joinSplitVolumes(indexPath, index, tempFile); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	if err := tempFile.Close(); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+
+	return tempFile.Name(), cleanup, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"2G", 2 << 30},
+		{"700M", 700 << 20},
+		{"512KB", 512 << 10},
+		{"1.5GiB", 3 << 29},
+		{"4096", 4096},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "G", "-1M", "abc"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) should fail", in)
+		}
+	}
+}
+
+// writeSplitBackup 写入随机内容并切分，返回原始内容和索引路径
+func writeSplitBackup(t *testing.T, size int, volumeSize int64) ([]byte, string) {
+	t.Helper()
+	content := make([]byte, size)
+	rand.Read(content)
+
+	orbitPath := filepath.Join(t.TempDir(), "backup.orbit")
+	if err := os.WriteFile(orbitPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := splitOrbitFile(orbitPath, volumeSize); err != nil {
+		t.Fatal(err)
+	}
+	return content, orbitPath
+}
+
+func TestSplitAndResolve(t *testing.T) {
+	content, orbitPath := writeSplitBackup(t, 10000, 3000)
+
+	index, err := readSplitIndex(orbitPath)
+	if err != nil || index == nil {
+		t.Fatalf("readSplitIndex: %v, %v", index, err)
+	}
+	if len(index.Volumes) != 4 {
+		t.Fatalf("got %d volumes, want 4", len(index.Volumes))
+	}
+
+	// 索引文件和任意分卷都可以作为入口
+	for _, entry := range []string{orbitPath, volumeName(orbitPath, 2)} {
+		resolved, cleanup, err := resolveOrbitFile(entry)
+		if err != nil {
+			t.Fatalf("%s: %v", entry, err)
+		}
+		joined, _ := os.ReadFile(resolved)
+		cleanup()
+		if !bytes.Equal(joined, content) {
+			t.Fatalf("%s: joined content differs", entry)
+		}
+	}
+}
+
+func TestSplitExactMultiple(t *testing.T) {
+	_, orbitPath := writeSplitBackup(t, 6000, 3000)
+
+	index, _ := readSplitIndex(orbitPath)
+	if len(index.Volumes) != 2 {
+		t.Fatalf("got %d volumes, want 2", len(index.Volumes))
+	}
+	if _, err := os.Stat(volumeName(orbitPath, 3)); !os.IsNotExist(err) {
+		t.Fatal("unexpected empty trailing volume")
+	}
+}
+
+func TestSplitRemovesStaleVolumes(t *testing.T) {
+	content, orbitPath := writeSplitBackup(t, 10000, 3000)
+
+	// 再次保存时备份变小，分卷变少
+	if err := os.WriteFile(orbitPath, content[:5000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := splitOrbitFile(orbitPath, 3000)
+	if err != nil || len(index.Volumes) != 2 {
+		t.Fatalf("split: %+v, %v", index, err)
+	}
+	for n := 3; n <= 4; n++ {
+		if _, err := os.Stat(volumeName(orbitPath, n)); !os.IsNotExist(err) {
+			t.Fatalf("stale volume %d was left behind", n)
+		}
+	}
+}
+
+func TestResolveMissingVolume(t *testing.T) {
+	_, orbitPath := writeSplitBackup(t, 10000, 3000)
+	os.Remove(volumeName(orbitPath, 3))
+
+	_, _, err := resolveOrbitFile(orbitPath)
+	if err == nil || !strings.Contains(err.Error(), "backup.orbit.003") {
+		t.Fatalf("got %v, want missing volume error", err)
+	}
+}
+
+func TestResolveVolumesOutOfOrder(t *testing.T) {
+	_, orbitPath := writeSplitBackup(t, 10000, 3000)
+
+	second, third := volumeName(orbitPath, 2), volumeName(orbitPath, 3)
+	os.Rename(second, second+".tmp")
+	os.Rename(third, second)
+	os.Rename(second+".tmp", third)
+
+	_, _, err := resolveOrbitFile(orbitPath)
+	if err == nil || !strings.Contains(err.Error(), "顺序错误") {
+		t.Fatalf("got %v, want out-of-order error", err)
+	}
+}
+
+func TestResolvePlainOrbitFile(t *testing.T) {
+	orbitPath := filepath.Join(t.TempDir(), "plain.orbit")
+	os.WriteFile(orbitPath, []byte("PK"), 0644)
+
+	resolved, cleanup, err := resolveOrbitFile(orbitPath)
+	defer cleanup()
+	if err != nil || resolved != orbitPath {
+		t.Fatalf("got %s, %v", resolved, err)
+	}
+}
@@ -45,7 +45,7 @@ func main() {
 	log.SetLevel(logrus.DebugLevel)
 
 	logDirTime := time.Now().Format("20060102_150405")
-	logDirPath := filepath.Join("logs/Log_" + logDirTime)
+	logDirPath := filepath.Join(cmd.LogDir(), "Log_"+logDirTime)
 	err := os.MkdirAll(logDirPath, 0644)
 	if err != nil {
 		log.Fatalf("无法创建日志文件: %v", err)